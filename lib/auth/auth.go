@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+	"github.com/uol/mycenae/lib/tsstats"
+)
+
+var stats *tsstats.StatsTS
+
+// Scope is the access level granted on a keyspace. Every scope includes the
+// ones below it
+type Scope int
+
+const (
+	None Scope = iota
+	Read
+	Write
+	Admin
+)
+
+// AnyKeyspace grants a scope on every keyspace. Endpoints that are not bound
+// to a keyspace, like keyspace creation, require it
+const AnyKeyspace = "*"
+
+func (s Scope) String() string {
+	switch s {
+	case Read:
+		return "read"
+	case Write:
+		return "write"
+	case Admin:
+		return "admin"
+	}
+	return "none"
+}
+
+func parseScope(s string) (Scope, bool) {
+	switch s {
+	case "read":
+		return Read, true
+	case "write":
+		return Write, true
+	case "admin":
+		return Admin, true
+	}
+	return None, false
+}
+
+// parseGrants reads grants in the keyspace:scope format
+func parseGrants(grants []string) (map[string]Scope, error) {
+
+	g := map[string]Scope{}
+
+	for _, grant := range grants {
+		i := strings.LastIndex(grant, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("grant %q needs to be in the keyspace:scope format", grant)
+		}
+		scope, ok := parseScope(grant[i+1:])
+		if !ok {
+			return nil, fmt.Errorf("grant %q has an unknown scope, use read, write or admin", grant)
+		}
+		if scope > g[grant[:i]] {
+			g[grant[:i]] = scope
+		}
+	}
+
+	return g, nil
+}
+
+// Principal is the owner of a token and the scopes it holds per keyspace
+type Principal struct {
+	Name   string
+	grants map[string]Scope
+}
+
+// Allowed tells if the principal holds scope on keyspace. A nil principal,
+// found when authentication is disabled, is allowed everything
+func (p *Principal) Allowed(keyspace string, scope Scope) bool {
+
+	if p == nil {
+		return true
+	}
+
+	return p.grants[keyspace] >= scope || p.grants[AnyKeyspace] >= scope
+}
+
+// Authorize returns a forbidden error when the principal doesn't hold scope
+// on keyspace
+func (p *Principal) Authorize(keyspace string, scope Scope) gobol.Error {
+
+	if p.Allowed(keyspace, scope) {
+		return nil
+	}
+
+	statsAuthFailure(keyspace, "scope")
+
+	if keyspace == AnyKeyspace {
+		return errForbidden(
+			"Authorize",
+			fmt.Sprintf("token %s needs the %s scope on every keyspace", p.Name, scope),
+		)
+	}
+
+	return errForbidden(
+		"Authorize",
+		fmt.Sprintf("token %s needs the %s scope on keyspace %s", p.Name, scope, keyspace),
+	)
+}
+
+// Authenticator resolves a token to its principal. It returns a nil
+// principal, and no error, for tokens it doesn't know
+type Authenticator interface {
+	Authenticate(token string) (*Principal, gobol.Error)
+}
+
+type ctxKey struct{}
+
+// FromRequest returns the principal that made the request, nil when
+// authentication is disabled
+func FromRequest(r *http.Request) *Principal {
+	p, _ := r.Context().Value(ctxKey{}).(*Principal)
+	return p
+}
+
+// New creates the authentication of the HTTP and UDP APIs. Tokens are tried
+// with the static tokens first and then as HMAC signed tokens when a secret
+// is configured
+func New(sts *tsstats.StatsTS, set structs.SettingsAuth) (*Auth, gobol.Error) {
+
+	stats = sts
+
+	a := &Auth{
+		enabled: set.Enabled,
+		udp:     set.Enabled && set.UDPtoken,
+	}
+
+	if !a.enabled {
+		return a, nil
+	}
+
+	st, err := newStaticTokens(set.Tokens)
+	if err != nil {
+		return nil, errInit(err.Error())
+	}
+
+	a.Use(st)
+
+	if set.HMACsecret != "" {
+		a.Use(&hmacTokens{secret: []byte(set.HMACsecret)})
+	}
+
+	return a, nil
+}
+
+// Auth wraps the router handlers, rejecting requests without a token that
+// holds the scope the endpoint requires
+type Auth struct {
+	enabled        bool
+	udp            bool
+	authenticators []Authenticator
+}
+
+// Use adds an authenticator, tried after the ones already added
+func (a *Auth) Use(au Authenticator) {
+	a.authenticators = append(a.authenticators, au)
+}
+
+func (a *Auth) on() bool {
+	return a != nil && a.enabled
+}
+
+// Authenticate returns the principal of a token
+func (a *Auth) Authenticate(token string) (*Principal, gobol.Error) {
+
+	if token == "" {
+		statsAuthFailure("", "missing")
+		return nil, errUnauthorized("Authenticate", "a token is required")
+	}
+
+	for _, au := range a.authenticators {
+		p, gerr := au.Authenticate(token)
+		if gerr != nil {
+			statsAuthFailure("", "invalid")
+			return nil, gerr
+		}
+		if p != nil {
+			return p, nil
+		}
+	}
+
+	statsAuthFailure("", "invalid")
+
+	return nil, errUnauthorized("Authenticate", "invalid token")
+}
+
+// token reads a bearer token from the Authorization header
+func token(r *http.Request) string {
+
+	h := r.Header.Get("Authorization")
+
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+
+	return ""
+}
+
+// authenticate resolves the principal of a request and stores it in the
+// request context
+func (a *Auth) authenticate(r *http.Request) (*http.Request, *Principal, gobol.Error) {
+
+	p, gerr := a.Authenticate(token(r))
+	if gerr != nil {
+		return r, nil, gerr
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), ctxKey{}, p)), p, nil
+}
+
+// Token only requires a valid token. The handler authorizes the request
+// itself, with FromRequest, when the keyspace is in the payload
+func (a *Auth) Token(h httprouter.Handle) httprouter.Handle {
+
+	if !a.on() {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+		r, _, gerr := a.authenticate(r)
+		if gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		h(w, r, ps)
+	}
+}
+
+// Keyspace requires scope on the keyspace in the path of the endpoint
+func (a *Auth) Keyspace(scope Scope, h httprouter.Handle) httprouter.Handle {
+
+	if !a.on() {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+		r, p, gerr := a.authenticate(r)
+		if gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		if gerr := p.Authorize(ps.ByName("keyspace"), scope); gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		h(w, r, ps)
+	}
+}
+
+// Global requires scope on every keyspace
+func (a *Auth) Global(scope Scope, h httprouter.Handle) httprouter.Handle {
+
+	if !a.on() {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+		r, p, gerr := a.authenticate(r)
+		if gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		if gerr := p.Authorize(AnyKeyspace, scope); gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		h(w, r, ps)
+	}
+}
+
+// UDPtoken tells if UDP points need a token tag
+func (a *Auth) UDPtoken() bool {
+	return a.on() && a.udp
+}
+
+// AuthorizeUDP checks that the token tag of a UDP point can write into its
+// keyspace
+func (a *Auth) AuthorizeUDP(token, keyspace string) gobol.Error {
+
+	if !a.UDPtoken() {
+		return nil
+	}
+
+	p, gerr := a.Authenticate(token)
+	if gerr != nil {
+		return gerr
+	}
+
+	return p.Authorize(keyspace, Write)
+}
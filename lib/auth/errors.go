@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+func errBasic(f, s string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			s,
+			code,
+			map[string]interface{}{
+				"package": "auth",
+				"func":    f,
+			},
+		)
+	}
+	return nil
+}
+
+func errInit(s string) gobol.Error {
+	return errBasic("New", s, http.StatusInternalServerError, errors.New(s))
+}
+
+func errUnauthorized(f, s string) gobol.Error {
+	return errBasic(f, s, http.StatusUnauthorized, errors.New(s))
+}
+
+func errForbidden(f, s string) gobol.Error {
+	return errBasic(f, s, http.StatusForbidden, errors.New(s))
+}
+
+func errClaims(e error) gobol.Error {
+	return errBasic("Authenticate", "invalid token claims", http.StatusUnauthorized, e)
+}
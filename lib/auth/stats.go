@@ -0,0 +1,15 @@
+package auth
+
+func statsAuthFailure(ks, reason string) {
+	if ks == "" {
+		ks = "default"
+	}
+	go statsIncrement(
+		"mycenae.auth.failure",
+		map[string]string{"keyspace": ks, "reason": reason},
+	)
+}
+
+func statsIncrement(metric string, tags map[string]string) {
+	stats.Increment("auth", metric, tags)
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// sampleToken is the token of the sample configuration, never accepted
+const sampleToken = "changeme"
+
+// staticTokens are the tokens listed in the configuration
+type staticTokens []staticToken
+
+type staticToken struct {
+	token     []byte
+	principal *Principal
+}
+
+func newStaticTokens(tokens []structs.SettingsToken) (staticTokens, error) {
+
+	st := staticTokens{}
+	seen := map[string]bool{}
+
+	for i, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("auth token %d has no token", i)
+		}
+		if t.Token == sampleToken {
+			return nil, fmt.Errorf("auth token %s is the sample token %q, replace it", t.Name, sampleToken)
+		}
+		if seen[t.Token] {
+			return nil, fmt.Errorf("auth token %s is repeated", t.Name)
+		}
+		seen[t.Token] = true
+
+		grants, err := parseGrants(t.Grants)
+		if err != nil {
+			return nil, err
+		}
+
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+
+		st = append(st, staticToken{
+			token:     []byte(t.Token),
+			principal: &Principal{Name: name, grants: grants},
+		})
+	}
+
+	return st, nil
+}
+
+// Authenticate compares the token with every configured one in constant
+// time, so the time taken doesn't tell how much of a token matched
+func (st staticTokens) Authenticate(token string) (*Principal, gobol.Error) {
+
+	var principal *Principal
+
+	for _, t := range st {
+		if subtle.ConstantTimeCompare(t.token, []byte(token)) == 1 {
+			principal = t.principal
+		}
+	}
+
+	return principal, nil
+}
+
+// hmacTokens are issued outside of mycenae as base64url(claims) + "." +
+// base64url(HMAC-SHA256(base64url(claims))), signed with the shared secret
+type hmacTokens struct {
+	secret []byte
+}
+
+type claims struct {
+	Name    string   `json:"name"`
+	Grants  []string `json:"grants"`
+	Expires int64    `json:"exp"`
+}
+
+func (ht *hmacTokens) Authenticate(token string) (*Principal, gobol.Error) {
+
+	i := strings.LastIndex(token, ".")
+	if i <= 0 {
+		return nil, nil
+	}
+
+	payload, signature := token[:i], token[i+1:]
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, nil
+	}
+
+	mac := hmac.New(sha256.New, ht.secret)
+	mac.Write([]byte(payload))
+
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errClaims(err)
+	}
+
+	c := claims{}
+
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, errClaims(err)
+	}
+
+	if c.Expires != 0 && time.Now().Unix() >= c.Expires {
+		return nil, errUnauthorized("Authenticate", fmt.Sprintf("token %s expired", c.Name))
+	}
+
+	grants, err := parseGrants(c.Grants)
+	if err != nil {
+		return nil, errClaims(err)
+	}
+
+	if c.Name == "" {
+		c.Name = "hmac"
+	}
+
+	return &Principal{Name: c.Name, grants: grants}, nil
+}
@@ -104,3 +104,13 @@ func (bc *Bcache) getTSID(esType, bucket, key string, CheckTSID func(esType, id
 
 	return true, nil
 }
+
+//Close closes boltdb, it must be called after everything using the cache stopped
+func (bc *Bcache) Close() gobol.Error {
+	return bc.persist.Close()
+}
+
+//Ping reads boltdb, telling if the cache can be used
+func (bc *Bcache) Ping() gobol.Error {
+	return bc.persist.Ping()
+}
@@ -10,9 +10,10 @@ import (
 
 func errBasic(f, s string, e error) gobol.Error {
 	if e != nil {
-		return tserr.New(
+		return tserr.NewCode(
 			e,
 			s,
+			tserr.StorageUnavailable,
 			http.StatusInternalServerError,
 			map[string]interface{}{
 				"package": "bcache/persistence",
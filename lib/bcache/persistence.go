@@ -1,6 +1,7 @@
 package bcache
 
 import (
+	"errors"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -117,3 +118,25 @@ func (persist *persistence) Delete(buckName, key []byte) gobol.Error {
 	statsSuccess("delete", buckName, time.Since(start))
 	return nil
 }
+
+func (persist *persistence) Close() gobol.Error {
+	if err := persist.db.Close(); err != nil {
+		return errPersist("Close", err)
+	}
+	return nil
+}
+
+func (persist *persistence) Ping() gobol.Error {
+
+	err := persist.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte("keyspace")) == nil {
+			return errors.New("bucket keyspace not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return errPersist("Ping", err)
+	}
+
+	return nil
+}
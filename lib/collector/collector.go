@@ -2,6 +2,7 @@ package collector
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"net"
@@ -17,7 +18,9 @@ import (
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rubber"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/bcache"
+	"github.com/uol/mycenae/lib/limiter"
 	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/tsstats"
 )
@@ -33,6 +36,8 @@ func New(
 	cass *gocql.Session,
 	es *rubber.Elastic,
 	bc *bcache.Bcache,
+	lim *limiter.Limiter,
+	au *auth.Auth,
 	set *structs.Settings,
 	consist []gocql.Consistency,
 ) (*Collector, error) {
@@ -42,11 +47,72 @@ func New(
 		return nil, err
 	}
 
+	var lsi time.Duration
+
+	if set.MetaLastSeenInterval != "" {
+		lsi, err = time.ParseDuration(set.MetaLastSeenInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	iw := time.Minute
+
+	if set.Probe.Window != "" {
+		iw, err = time.ParseDuration(set.Probe.Window)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sto := 30 * time.Second
+
+	if set.ShutdownTimeout != "" {
+		sto, err = time.ParseDuration(set.ShutdownTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var retention time.Duration
+
+	if set.Errors.Retention != "" {
+		retention, err = time.ParseDuration(set.Errors.Retention)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ci := time.Hour
+
+	if set.Errors.CleanupInterval != "" {
+		ci, err = time.ParseDuration(set.Errors.CleanupInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ci <= 0 {
+		return nil, errors.New("errors cleanupInterval needs to be positive")
+	}
+
+	dll := set.Errors.DeadLetterLimit
+	if dll <= 0 {
+		dll = 10000
+	}
+
+	batch := set.Errors.CleanupBatch
+	if batch <= 0 {
+		batch = 1000
+	}
+
 	gblog = log.General
 	stats = sts
 
 	collect := &Collector{
 		boltc:       bc,
+		limiter:     lim,
+		auth:        au,
 		persist:     persistence{cassandra: cass, esearch: es, consistencies: consist},
 		validKey:    regexp.MustCompile(`^[0-9A-Za-z-._%&#;/]+$`),
 		settings:    set,
@@ -54,15 +120,41 @@ func New(
 		concBulk:    make(chan struct{}, set.MaxConcurrentBulks),
 		metaChan:    make(chan Point, set.MetaBufferSize),
 		metaPayload: &bytes.Buffer{},
+		lastSeen:    make([]*lsShard, lsShards),
+		lsInterval:  int64(lsi / time.Millisecond),
+		stopMeta:    make(chan struct{}),
+		metaDone:    make(chan struct{}),
+		drained:     make(chan struct{}),
+		ingest:      newWindow(iw),
+
+		shutdownTimeout: sto,
+
+		retention:    retention,
+		cleanupBatch: batch,
+		stopCleanup:  make(chan struct{}),
+		errorIndexes: map[string]bool{},
+		dlLimit:      dll,
+		dlCount:      map[string]int{},
+	}
+
+	for i := range collect.lastSeen {
+		collect.lastSeen[i] = &lsShard{
+			seen:     map[string]seenRange{},
+			pending:  map[string]lastSeen{},
+			admitted: map[string]int64{},
+		}
 	}
 
 	go collect.metaCoordinator(d)
+	go collect.errorCleanup(ci)
 
 	return collect, nil
 }
 
 type Collector struct {
 	boltc    *bcache.Bcache
+	limiter  *limiter.Limiter
+	auth     *auth.Auth
 	persist  persistence
 	validKey *regexp.Regexp
 	settings *structs.Settings
@@ -72,19 +164,66 @@ type Collector struct {
 	metaChan    chan Point
 	metaPayload *bytes.Buffer
 
-	receivedSinceLastProbe float64
-	errorsSinceLastProbe   float64
-	saving                 float64
-	shutdown               bool
-	saveMutex              sync.Mutex
-	recvMutex              sync.Mutex
-	errMutex               sync.Mutex
+	lastSeen   []*lsShard
+	lsInterval int64
+	lsPruned   int64
+
+	written func(keyspace, key string, timestamp int64)
+
+	stopMeta chan struct{}
+	metaDone chan struct{}
+	metaLost int
+
+	ingest *window
+
+	retention    time.Duration
+	cleanupBatch int
+	stopCleanup  chan struct{}
+	errorIndexes map[string]bool
+
+	dlLimit int
+	dlDay   int
+	dlCount map[string]int
+	dlMutex sync.Mutex
+
+	saving          int
+	rejected        int
+	shutdown        bool
+	shutdownTimeout time.Duration
+	drained         chan struct{}
+	saveMutex       sync.Mutex
 }
 
 func (collect *Collector) SetConsistencies(consistencies []gocql.Consistency) {
 	collect.persist.SetConsistencies(consistencies)
 }
 
+// OnWrite registers a function called with the keyspace, bucket key and
+// timestamp of every number point saved
+func (collect *Collector) OnWrite(fn func(keyspace, key string, timestamp int64)) {
+	collect.written = fn
+}
+
+// Consistencies returns the consistencies points are written with
+func (collect *Collector) Consistencies() []gocql.Consistency {
+	return collect.persist.consistencies
+}
+
+// PingES searches the main index, telling if elasticsearch can serve it
+func (collect *Collector) PingES() gobol.Error {
+	return collect.persist.PingES(collect.settings.ElasticSearch.Index)
+}
+
+// MetaQueue returns the points waiting to be indexed and the queue size
+func (collect *Collector) MetaQueue() (int, int) {
+	return len(collect.metaChan), cap(collect.metaChan)
+}
+
+// ConcurrentPoints returns the points being saved and the concurrency limit
+func (collect *Collector) ConcurrentPoints() (int, int) {
+	return len(collect.concPoints), cap(collect.concPoints)
+}
+
 func (collect *Collector) CheckUDPbind() bool {
 	lf := logrus.Fields{
 		"struct": "CollectorV2",
@@ -107,48 +246,138 @@ func (collect *Collector) CheckUDPbind() bool {
 	return false
 }
 
-func (collect *Collector) ReceivedErrorRatio() (ratio float64) {
-	lf := logrus.Fields{
-		"struct": "CollectorV2",
-		"func":   "ReceivedErrorRatio",
-	}
+// ReceivedErrorRatio returns the ratio of errors to points received in the
+// probe window
+func (collect *Collector) ReceivedErrorRatio() float64 {
+	return collect.IngestStats().ErrorRatio
+}
 
-	if collect.receivedSinceLastProbe == 0 {
-		ratio = 0
-	} else {
-		ratio = collect.errorsSinceLastProbe / collect.receivedSinceLastProbe
-	}
+// IngestStats returns the points received and the errors in the probe window
+func (collect *Collector) IngestStats() IngestStats {
+
+	received, errors := collect.ingest.sum()
 
-	gblog.WithFields(lf).Debug(ratio)
+	is := IngestStats{
+		Window:   collect.ingest.size().String(),
+		Received: received,
+		Errors:   errors,
+		Rate:     float64(received) / collect.ingest.size().Seconds(),
+	}
 
-	collect.recvMutex.Lock()
-	collect.receivedSinceLastProbe = 0
-	collect.recvMutex.Unlock()
-	collect.errMutex.Lock()
-	collect.errorsSinceLastProbe = 0
-	collect.errMutex.Unlock()
+	if received > 0 {
+		is.ErrorRatio = float64(errors) / float64(received)
+	}
 
-	return
+	return is
 }
 
+// Stop rejects new points, waits for the writes in flight and indexes the
+// buffered metadata, giving up after ShutdownTimeout. The listeners need to
+// be stopped before
 func (collect *Collector) Stop() {
+
+	deadline := time.After(collect.shutdownTimeout)
+
+	close(collect.stopCleanup)
+
+	collect.saveMutex.Lock()
 	collect.shutdown = true
-	for {
-		if collect.saving <= 0 {
-			return
+	if collect.saving == 0 {
+		close(collect.drained)
+	}
+	collect.saveMutex.Unlock()
+
+	lf := logrus.Fields{
+		"package": "collector",
+		"func":    "Stop",
+	}
+
+	drained := true
+
+	select {
+	case <-collect.drained:
+	case <-deadline:
+		drained = false
+	}
+
+	flushed := false
+
+	if drained {
+		close(collect.stopMeta)
+
+		select {
+		case <-collect.metaDone:
+			flushed = true
+		case <-deadline:
 		}
 	}
+
+	collect.saveMutex.Lock()
+	rejected, abandoned := collect.rejected, collect.saving
+	collect.saveMutex.Unlock()
+
+	lf["rejected"] = rejected
+	lf["abandoned"] = abandoned
+	lf["metaFlushed"] = flushed
+
+	if flushed {
+		lf["metaLost"] = collect.metaLost
+	}
+
+	if !flushed || rejected > 0 || collect.metaLost > 0 {
+		gblog.WithFields(lf).Warn("collector stopped before saving everything")
+		return
+	}
+
+	gblog.WithFields(lf).Info("collector stopped")
+}
+
+// begin registers a write in flight, it fails once the collector is stopping
+func (collect *Collector) begin() bool {
+
+	collect.saveMutex.Lock()
+	defer collect.saveMutex.Unlock()
+
+	if collect.shutdown {
+		collect.rejected++
+		return false
+	}
+
+	collect.saving++
+
+	return true
+}
+
+// track registers work started by a write in flight, that has to finish
+// before the collector stops
+func (collect *Collector) track() {
+	collect.saveMutex.Lock()
+	collect.saving++
+	collect.saveMutex.Unlock()
+}
+
+func (collect *Collector) done() {
+
+	collect.saveMutex.Lock()
+	defer collect.saveMutex.Unlock()
+
+	collect.saving--
+
+	if collect.shutdown && collect.saving == 0 {
+		close(collect.drained)
+	}
 }
 
 func (collect *Collector) HandlePacket(rcvMsg TSDBpoint, number bool) gobol.Error {
 
 	start := time.Now()
 
-	go func() {
-		collect.recvMutex.Lock()
-		collect.receivedSinceLastProbe++
-		collect.recvMutex.Unlock()
-	}()
+	if !collect.begin() {
+		return errShutdown("HandlePacket")
+	}
+	defer collect.done()
+
+	collect.ingest.received()
 
 	packet := Point{}
 
@@ -157,6 +386,11 @@ func (collect *Collector) HandlePacket(rcvMsg TSDBpoint, number bool) gobol.Erro
 		return gerr
 	}
 
+	gerr = collect.limiter.AllowPoints(packet.KsID, 1)
+	if gerr != nil {
+		return gerr
+	}
+
 	if number {
 		if packet.Tuuid {
 			gerr = collect.saveTUUIDvalue(packet)
@@ -172,13 +406,16 @@ func (collect *Collector) HandlePacket(rcvMsg TSDBpoint, number bool) gobol.Erro
 	}
 
 	if gerr != nil {
-		collect.errMutex.Lock()
-		collect.errorsSinceLastProbe++
-		collect.errMutex.Unlock()
+		collect.ingest.failed()
 		return gerr
 	}
 
+	if number && collect.written != nil {
+		collect.written(packet.KsID, fmt.Sprintf("%v%v", packet.Bucket, packet.ID), packet.Timestamp)
+	}
+
 	if len(collect.metaChan) < collect.settings.MetaBufferSize {
+		collect.track()
 		go collect.saveMeta(packet)
 	} else {
 		gblog.WithFields(logrus.Fields{
@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+const (
+	sourceUDP  = "udp"
+	sourceHTTP = "http"
+
+	deadLetterPage     = 50
+	deadLetterMaxPage  = 1000
+	deadLetterMaxRange = 31 * 24 * time.Hour
+)
+
+// DeadLetter is a point rejected by the UDP or the HTTP API, kept with the
+// reason it was rejected so it can be inspected and replayed
+type DeadLetter struct {
+	ID      gocql.UUID `json:"id"`
+	Date    time.Time  `json:"date"`
+	Source  string     `json:"source"`
+	Number  bool       `json:"number"`
+	Metric  string     `json:"metric,omitempty"`
+	TSID    string     `json:"tsid,omitempty"`
+	Reason  tserr.Code `json:"reason"`
+	Error   string     `json:"error"`
+	Payload string     `json:"payload"`
+}
+
+// DeadLetterQuery selects the dead letters of a keyspace between Start and
+// End, optionally of a single reason
+type DeadLetterQuery struct {
+	Keyspace string
+	Start    time.Time
+	End      time.Time
+	Reason   string
+}
+
+// deadLetterDay is the partition of a dead letter, the days since epoch
+func deadLetterDay(t time.Time) int {
+	return int(t.Unix() / int64(24*time.Hour/time.Second))
+}
+
+// days returns the partitions of the query, newest first
+func (q DeadLetterQuery) days() []int {
+
+	days := []int{}
+
+	for d := deadLetterDay(q.End); d >= deadLetterDay(q.Start); d-- {
+		days = append(days, d)
+	}
+
+	return days
+}
+
+// deadLettered tells if a rejected point is kept. Points rejected by
+// authentication are not, a client can't fill the keyspaces of others, nor
+// the ones shed by a quota or during shutdown, saving them would put the load
+// back on cassandra. Those are only counted
+func deadLettered(gerr gobol.Error) bool {
+	switch gerr.StatusCode() {
+	case http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return false
+	}
+	return true
+}
+
+// deadLetter keeps a rejected point in the keyspace it was sent to. Points
+// without a keyspace, or sent to one that doesn't exist, go to the default
+// keyspace and can be replayed into another one. Past the daily limit of the
+// keyspace the points are only counted
+func (collect *Collector) deadLetter(source string, number bool, point TSDBpoint, payload string, cause gobol.Error) gobol.Error {
+
+	keyspace := collect.settings.Cassandra.Keyspace
+
+	if ksid := point.Tags["ksid"]; ksid != "" {
+		_, found, gerr := collect.boltc.GetKeyspace(ksid)
+		if gerr != nil {
+			return gerr
+		}
+		if found {
+			keyspace = ksid
+		}
+	}
+
+	if !deadLettered(cause) || !collect.allowDeadLetter(keyspace) {
+		statsDeadLetterDropped(keyspace, source)
+		return nil
+	}
+
+	dl := DeadLetter{
+		ID:      gocql.TimeUUID(),
+		Source:  source,
+		Number:  number,
+		Metric:  point.Metric,
+		Reason:  tserr.CodeOf(cause),
+		Error:   cause.Message(),
+		Payload: redactToken(payload),
+	}
+
+	if point.Metric != "" || len(point.Tags) > 0 {
+		dl.TSID = GenerateID(point)
+		if !number {
+			dl.TSID = fmt.Sprintf("T%v", dl.TSID)
+		}
+	}
+
+	statsDeadLetter(keyspace, source)
+
+	return collect.persist.InsertDeadLetter(keyspace, dl, collect.errorTTL())
+}
+
+// allowDeadLetter counts the dead letters saved by the node in the keyspace
+// today and tells if the limit was not reached
+func (collect *Collector) allowDeadLetter(keyspace string) bool {
+
+	day := deadLetterDay(time.Now())
+
+	collect.dlMutex.Lock()
+	defer collect.dlMutex.Unlock()
+
+	if day != collect.dlDay {
+		collect.dlDay = day
+		collect.dlCount = map[string]int{}
+	}
+
+	if collect.dlCount[keyspace] >= collect.dlLimit {
+		return false
+	}
+
+	collect.dlCount[keyspace]++
+
+	return true
+}
+
+// DeadLetters returns a page of dead letters, newest first, and the cursor
+// of the next page, empty on the last one
+func (collect *Collector) DeadLetters(q DeadLetterQuery, size int, cursor *gocql.UUID) ([]DeadLetter, string, gobol.Error) {
+
+	dls := []DeadLetter{}
+
+	for _, day := range q.days() {
+
+		if cursor != nil && day > deadLetterDay(cursor.Time()) {
+			continue
+		}
+
+		page, gerr := collect.persist.SelectDeadLetters(q.Keyspace, day, q.Start, q.End, cursor, q.Reason, size+1-len(dls))
+		if gerr != nil {
+			return nil, "", gerr
+		}
+
+		dls = append(dls, page...)
+
+		if len(dls) > size {
+			break
+		}
+	}
+
+	for i := range dls {
+		dls[i].Date = dls[i].ID.Time()
+	}
+
+	if len(dls) > size {
+		dls = dls[:size]
+		return dls, dls[size-1].ID.String(), nil
+	}
+
+	return dls, "", nil
+}
+
+// CountDeadLetters counts the dead letters of the query by reason
+func (collect *Collector) CountDeadLetters(q DeadLetterQuery) (map[string]int, gobol.Error) {
+
+	count := map[string]int{}
+
+	for _, day := range q.days() {
+
+		c, gerr := collect.persist.CountDeadLetters(q.Keyspace, day, q.Start, q.End)
+		if gerr != nil {
+			return nil, gerr
+		}
+
+		for reason, n := range c {
+			if q.Reason == "" || q.Reason == reason {
+				count[reason] += n
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// PurgeDeadLetters deletes the dead letters of the query and returns how
+// many were deleted
+func (collect *Collector) PurgeDeadLetters(q DeadLetterQuery) (int, gobol.Error) {
+
+	purged := 0
+
+	for _, day := range q.days() {
+
+		var cursor *gocql.UUID
+
+		for {
+			dls, gerr := collect.persist.SelectDeadLetters(q.Keyspace, day, q.Start, q.End, cursor, q.Reason, deadLetterMaxPage)
+			if gerr != nil {
+				return purged, gerr
+			}
+
+			if len(dls) == 0 {
+				break
+			}
+
+			ids := make([]gocql.UUID, len(dls))
+			for i, dl := range dls {
+				ids[i] = dl.ID
+			}
+
+			if gerr := collect.persist.DeleteDeadLetters(q.Keyspace, day, ids); gerr != nil {
+				return purged, gerr
+			}
+
+			purged += len(ids)
+
+			if len(dls) < deadLetterMaxPage {
+				break
+			}
+
+			cursor = &ids[len(ids)-1]
+		}
+	}
+
+	return purged, nil
+}
+
+// ReplayDeadLetter sends a dead letter through HandlePacket again, with its
+// keyspace replaced by ksid when it is set. The dead letter is deleted when
+// the point is saved and kept when it is rejected again
+func (collect *Collector) ReplayDeadLetter(keyspace string, id gocql.UUID, ksid string) gobol.Error {
+
+	dl, gerr := collect.persist.GetDeadLetter(keyspace, id)
+	if gerr != nil {
+		return gerr
+	}
+
+	if dl == nil {
+		return errCode(
+			"ReplayDeadLetter",
+			fmt.Sprintf("dead letter %s not found", id),
+			tserr.NotFound,
+			http.StatusNotFound,
+			fmt.Errorf("dead letter %s not found", id),
+		)
+	}
+
+	point := TSDBpoint{}
+
+	if err := json.Unmarshal([]byte(dl.Payload), &point); err != nil {
+		return errUnmarshal("ReplayDeadLetter", err)
+	}
+
+	if ksid != "" {
+		if point.Tags == nil {
+			point.Tags = map[string]string{}
+		}
+		point.Tags["ksid"] = ksid
+	}
+
+	if gerr := collect.HandlePacket(point, dl.Number); gerr != nil {
+		return gerr
+	}
+
+	return collect.persist.DeleteDeadLetters(keyspace, deadLetterDay(id.Time()), []gocql.UUID{id})
+}
@@ -39,12 +39,47 @@ func errISE(f, s string, e error) gobol.Error {
 	return nil
 }
 
-func errValidation(s string) gobol.Error {
-	return errBR("makePacket", s, errors.New(s))
+func errShutdown(f string) gobol.Error {
+	s := "mycenae is shutting down"
+	return tserr.NewCode(
+		errors.New(s),
+		s,
+		tserr.ShuttingDown,
+		http.StatusServiceUnavailable,
+		map[string]interface{}{
+			"package": "collector",
+			"func":    f,
+		},
+	)
+}
+
+func errCode(f, s string, code tserr.Code, httpCode int, e error) gobol.Error {
+	return tserr.NewCode(
+		e,
+		s,
+		code,
+		httpCode,
+		map[string]interface{}{
+			"package": "collector",
+			"func":    f,
+		},
+	)
+}
+
+func errValidation(code tserr.Code, s string) gobol.Error {
+	return errCode("makePacket", s, code, http.StatusBadRequest, errors.New(s))
+}
+
+func errReservedKeyspace(s string) gobol.Error {
+	return errCode("makePacket", s, tserr.ReservedKeyspace, http.StatusForbidden, errors.New(s))
+}
+
+func errTimestamp(f string, e error) gobol.Error {
+	return errCode(f, e.Error(), tserr.InvalidTimestamp, http.StatusBadRequest, e)
 }
 
 func errUnmarshal(f string, e error) gobol.Error {
-	return errBR(f, "Wrong JSON format", e)
+	return errCode(f, "Wrong JSON format", tserr.InvalidJSON, http.StatusBadRequest, e)
 }
 
 func errMarshal(f string, e error) gobol.Error {
@@ -52,5 +87,9 @@ func errMarshal(f string, e error) gobol.Error {
 }
 
 func errPersist(f string, e error) gobol.Error {
-	return errISE(f, e.Error(), e)
+	return errCode(f, e.Error(), tserr.StorageUnavailable, http.StatusInternalServerError, e)
+}
+
+func errDeadLetterParam(f, s string) gobol.Error {
+	return errCode(f, s, tserr.InvalidRequest, http.StatusBadRequest, errors.New(s))
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"time"
 
@@ -14,11 +15,25 @@ import (
 func (collect *Collector) metaCoordinator(saveInterval time.Duration) {
 
 	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
+		case <-collect.stopMeta:
+
+			collect.flushMeta()
+			close(collect.metaDone)
+			return
+
 		case <-ticker.C:
 
+			gerr := collect.generateLastSeen()
+			if gerr != nil {
+				gblog.WithFields(logrus.Fields{
+					"func": "collector/metaCoordinator/generateLastSeen",
+				}).Error(gerr.Error())
+			}
+
 			if collect.metaPayload.Len() != 0 {
 
 				collect.concBulk <- struct{}{}
@@ -30,6 +45,7 @@ func (collect *Collector) metaCoordinator(saveInterval time.Duration) {
 					gblog.WithFields(logrus.Fields{
 						"func": "collector/metaCoordinator",
 					}).Error(err)
+					<-collect.concBulk
 					continue
 				}
 
@@ -57,6 +73,7 @@ func (collect *Collector) metaCoordinator(saveInterval time.Duration) {
 					gblog.WithFields(logrus.Fields{
 						"func": "collector/metaCoordinator",
 					}).Error(err)
+					<-collect.concBulk
 					continue
 				}
 
@@ -66,6 +83,50 @@ func (collect *Collector) metaCoordinator(saveInterval time.Duration) {
 	}
 }
 
+// flushMeta indexes the points still queued and the metadata buffered when
+// the collector stops. It waits for the bulks in flight and then saves the
+// remaining ones in sequence
+func (collect *Collector) flushMeta() {
+
+	lf := logrus.Fields{
+		"func": "collector/flushMeta",
+	}
+
+	for len(collect.metaChan) > 0 {
+		gerr := collect.generateBulk(<-collect.metaChan)
+		if gerr != nil {
+			gblog.WithFields(lf).Error(gerr.Error())
+		}
+	}
+
+	gerr := collect.generateLastSeen()
+	if gerr != nil {
+		gblog.WithFields(lf).Error(gerr.Error())
+	}
+
+	for i := 0; i < cap(collect.concBulk); i++ {
+		collect.concBulk <- struct{}{}
+	}
+
+	for collect.metaPayload.Len() > 0 {
+
+		bulk := &bytes.Buffer{}
+
+		err := collect.readMeta(bulk)
+		if err != nil {
+			gblog.WithFields(lf).Error(err)
+			collect.metaLost++
+			return
+		}
+
+		gerr := collect.persist.SaveBulkES(bulk)
+		if gerr != nil {
+			gblog.WithFields(lf).Error(gerr.Error())
+			collect.metaLost++
+		}
+	}
+}
+
 func (collect *Collector) readMeta(bulk *bytes.Buffer) error {
 
 	for {
@@ -91,6 +152,8 @@ func (collect *Collector) readMeta(bulk *bytes.Buffer) error {
 
 func (collect *Collector) saveMeta(packet Point) {
 
+	defer collect.done()
+
 	found := false
 
 	var gerr gobol.Error
@@ -106,16 +169,280 @@ func (collect *Collector) saveMeta(packet Point) {
 		gblog.WithFields(logrus.Fields{
 			"func": "collector/saveMeta",
 		}).Error(gerr.Error())
-		collect.errMutex.Lock()
-		collect.errorsSinceLastProbe++
-		collect.errMutex.Unlock()
+		collect.ingest.failed()
 	}
 
 	if !found {
+		collect.touchMeta(ksts, packet, true)
 		collect.metaChan <- packet
 		statsBulkPoints()
+		return
+	}
+
+	collect.touchMeta(ksts, packet, false)
+}
+
+// allowSeries checks the new series quota of the keyspace when the timeseries
+// was not seen by this node and is not indexed yet. The timeseries is admitted
+// before its points are saved, so a burst of points of a new timeseries takes
+// a single series from the quota
+func (collect *Collector) allowSeries(packet Point) gobol.Error {
+
+	ksts := fmt.Sprintf("%v|%v", packet.KsID, packet.ID)
+
+	shard := collect.seenShard(ksts)
+
+	shard.Lock()
+	_, seen := shard.seen[ksts]
+	_, admitted := shard.admitted[ksts]
+	if !seen && !admitted {
+		shard.admitted[ksts] = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	shard.Unlock()
+
+	if seen || admitted {
+		return nil
+	}
+
+	var found bool
+	var gerr gobol.Error
+
+	if packet.Number {
+		found, gerr = collect.boltc.GetTsNumber(ksts, collect.CheckTSID)
+	} else {
+		found, gerr = collect.boltc.GetTsText(ksts, collect.CheckTSID)
+	}
+	if gerr != nil || found {
+		return nil
+	}
+
+	gerr = collect.limiter.AllowSeries(packet.KsID, packet.Message.Metric, collect.countSeries)
+	if gerr != nil {
+		shard.Lock()
+		delete(shard.admitted, ksts)
+		shard.Unlock()
+	}
+
+	return gerr
+}
+
+// countSeries counts the number of series indexed in a keyspace, or only the
+// ones of a metric. The metric is matched on metric.raw, documents of indexes
+// created before it was mapped are matched on the analyzed metric
+func (collect *Collector) countSeries(ksid, metric string) (int, gobol.Error) {
+
+	esType := "meta"
+
+	query := EsCount{}
+
+	if metric != "" {
+		query.Query = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					EsTerm{Term: map[string]string{"metric.raw": metric}},
+					map[string]interface{}{
+						"bool": map[string]interface{}{
+							"must": map[string]interface{}{
+								"match_phrase": map[string]string{"metric": metric},
+							},
+							"must_not": map[string]interface{}{
+								"exists": map[string]string{"field": "metric.raw"},
+							},
+						},
+					},
+				},
+			},
+		}
 	}
 
+	return collect.persist.CountMetaES(ksid, esType, query)
+}
+
+// seenShard returns the shard that holds the time range of a timeseries
+func (collect *Collector) seenShard(ksts string) *lsShard {
+	h := fnv.New32a()
+	h.Write([]byte(ksts))
+	return collect.lastSeen[h.Sum32()%lsShards]
+}
+
+// touchMeta records the time range of a timeseries and schedules an update of
+// its last seen timestamp, at most once per MetaLastSeenInterval, and of its
+// first seen timestamp when a point older than the ones seen is backfilled
+func (collect *Collector) touchMeta(ksts string, packet Point, indexed bool) {
+
+	shard := collect.seenShard(ksts)
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	delete(shard.admitted, ksts)
+
+	sr, ok := shard.seen[ksts]
+	sr.touched = time.Now().UnixNano() / int64(time.Millisecond)
+
+	if !ok {
+		sr.first = packet.Timestamp
+		sr.last = packet.Timestamp
+	}
+
+	if indexed {
+		if packet.Timestamp < sr.first {
+			sr.first = packet.Timestamp
+		}
+		if packet.Timestamp > sr.last {
+			sr.last = packet.Timestamp
+		}
+		sr.known = true
+		shard.seen[ksts] = sr
+		return
+	}
+
+	ls, scheduled := shard.pending[ksts]
+
+	if ok && packet.Timestamp < sr.first {
+		sr.first = packet.Timestamp
+		ls.firstSeen = packet.Timestamp
+		ls.known = sr.known
+		scheduled = true
+	}
+
+	if !ok || packet.Timestamp-sr.last >= collect.lsInterval {
+		sr.last = packet.Timestamp
+		ls.timestamp = packet.Timestamp
+		scheduled = true
+	}
+
+	shard.seen[ksts] = sr
+
+	if !scheduled {
+		return
+	}
+
+	ls.ksid = packet.KsID
+	ls.esType = "meta"
+	if !packet.Number {
+		ls.esType = "metatext"
+	}
+	ls.id = packet.ID
+
+	shard.pending[ksts] = ls
+}
+
+// pruneLastSeen drops the timeseries without points for longer than
+// MetaLastSeenInterval, so only the active ones are kept in memory. It runs
+// at most once per interval
+func (collect *Collector) pruneLastSeen() {
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	if now-collect.lsPruned < collect.lsInterval {
+		return
+	}
+
+	collect.lsPruned = now
+
+	for _, shard := range collect.lastSeen {
+		shard.Lock()
+		for ksts, sr := range shard.seen {
+			if now-sr.touched > collect.lsInterval {
+				delete(shard.seen, ksts)
+			}
+		}
+		for ksts, t := range shard.admitted {
+			if now-t > collect.lsInterval {
+				delete(shard.admitted, ksts)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+// lowerFirstSeen reads the firstSeen of a timeseries indexed before the node
+// started, it returns the firstSeen of ls when it is older and zero when the
+// indexed one must be kept. Documents without firstSeen are never pruned by
+// queries, so they are left without it
+func (collect *Collector) lowerFirstSeen(ksts string, ls lastSeen) int64 {
+
+	indexed, gerr := collect.persist.FirstSeenES(ls.ksid, ls.esType, ls.id)
+	if gerr != nil {
+		gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+		return 0
+	}
+
+	shard := collect.seenShard(ksts)
+
+	shard.Lock()
+	if sr, ok := shard.seen[ksts]; ok {
+		if indexed < sr.first {
+			sr.first = indexed
+		}
+		sr.known = true
+		shard.seen[ksts] = sr
+	}
+	shard.Unlock()
+
+	if indexed == 0 || indexed <= ls.firstSeen {
+		return 0
+	}
+
+	return ls.firstSeen
+}
+
+func (collect *Collector) generateLastSeen() gobol.Error {
+
+	collect.pruneLastSeen()
+
+	pending := map[string]lastSeen{}
+
+	for _, shard := range collect.lastSeen {
+		shard.Lock()
+		for ksts, ls := range shard.pending {
+			pending[ksts] = ls
+		}
+		shard.pending = map[string]lastSeen{}
+		shard.Unlock()
+	}
+
+	for ksts, ls := range pending {
+
+		if ls.firstSeen != 0 && !ls.known {
+			ls.firstSeen = collect.lowerFirstSeen(ksts, ls)
+		}
+
+		if ls.firstSeen == 0 && ls.timestamp == 0 {
+			continue
+		}
+
+		idx := BulkUpdate{
+			ID: EsIndex{
+				EsIndex: ls.ksid,
+				EsType:  ls.esType,
+				EsID:    ls.id,
+			},
+		}
+
+		indexJSON, err := json.Marshal(idx)
+		if err != nil {
+			return errMarshal("generateLastSeen", err)
+		}
+
+		doc := EsLastSeen{}
+		doc.Doc.FirstSeen = ls.firstSeen
+		doc.Doc.LastSeen = ls.timestamp
+
+		docJSON, err := json.Marshal(doc)
+		if err != nil {
+			return errMarshal("generateLastSeen", err)
+		}
+
+		collect.metaPayload.Write(indexJSON)
+		collect.metaPayload.WriteString("\n")
+		collect.metaPayload.Write(docJSON)
+		collect.metaPayload.WriteString("\n")
+		collect.metaPayload.WriteString("|")
+	}
+
+	return nil
 }
 
 func (collect *Collector) generateBulk(packet Point) gobol.Error {
@@ -247,10 +574,22 @@ func (collect *Collector) generateBulk(packet Point) gobol.Error {
 	collect.metaPayload.WriteString("\n")
 
 	docM := MetaInfo{
-		ID:     packet.ID,
-		Metric: packet.Message.Metric,
-		Tags:   cleanTags,
+		ID:        packet.ID,
+		Metric:    packet.Message.Metric,
+		Tags:      cleanTags,
+		FirstSeen: packet.Timestamp,
+		LastSeen:  packet.Timestamp,
+	}
+
+	ksts := fmt.Sprintf("%v|%v", packet.KsID, packet.ID)
+	shard := collect.seenShard(ksts)
+
+	shard.Lock()
+	if sr, ok := shard.seen[ksts]; ok && sr.known {
+		docM.FirstSeen = sr.first
+		docM.LastSeen = sr.last
 	}
+	shard.Unlock()
 
 	docJSON, err = json.Marshal(docM)
 	if err != nil {
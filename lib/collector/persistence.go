@@ -3,12 +3,15 @@ package collector
 import (
 	"fmt"
 	"io"
+	"net/http"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rubber"
+
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 type persistence struct {
@@ -125,17 +128,19 @@ func (persist *persistence) InsertTUUIDtext(ksid, tsid string, timeU gocql.UUID,
 	return errPersist("InsertTUUIDtext", err)
 }
 
-func (persist *persistence) InsertError(id, msg, errMsg string, date time.Time) gobol.Error {
+func (persist *persistence) InsertError(id, msg, errMsg string, code tserr.Code, date time.Time, ttl int) gobol.Error {
 	start := time.Now()
 	var err error
 	for _, cons := range persist.consistencies {
 		if err = persist.cassandra.Query(
-			`INSERT INTO ts_error (code, tsid, error, message, date) VALUES (?, ?, ?, ?, ?)`,
+			`INSERT INTO ts_error (code, tsid, error, error_code, message, date) VALUES (?, ?, ?, ?, ?, ?) USING TTL ?`,
 			0,
 			id,
 			errMsg,
+			string(code),
 			msg,
 			date,
+			ttl,
 		).Consistency(cons).RoutingKey([]byte(id)).Exec(); err != nil {
 			statsInsertQerror("default", "ts_error")
 			gblog.WithFields(
@@ -164,6 +169,38 @@ func (persist *persistence) HeadMetaFromES(index, eType, id string) (int, gobol.
 	return respCode, nil
 }
 
+// FirstSeenES returns the firstSeen of a meta document, zero when the
+// document doesn't exist or doesn't have it
+func (persist *persistence) FirstSeenES(index, eType, id string) (int64, gobol.Error) {
+	start := time.Now()
+
+	var resp EsMetaSeen
+
+	_, err := persist.esearch.GetById(index, eType, id, &resp)
+	if err != nil {
+		statsIndexError(index, eType, "get")
+		return 0, errPersist("FirstSeenES", err)
+	}
+
+	statsIndex(index, eType, "get", time.Since(start))
+	return resp.Source.FirstSeen, nil
+}
+
+func (persist *persistence) CountMetaES(index, eType string, query EsCount) (int, gobol.Error) {
+	start := time.Now()
+
+	var resp EsCountResponse
+
+	_, err := persist.esearch.Query(index, eType, query, &resp)
+	if err != nil {
+		statsIndexError(index, eType, "post")
+		return 0, errPersist("CountMetaES", err)
+	}
+
+	statsIndex(index, eType, "post", time.Since(start))
+	return resp.Hits.Total, nil
+}
+
 func (persist *persistence) SendErrorToES(index, eType, id string, doc StructV2Error) gobol.Error {
 	start := time.Now()
 	_, err := persist.esearch.Put(index, eType, id, doc)
@@ -175,6 +212,87 @@ func (persist *persistence) SendErrorToES(index, eType, id string, doc StructV2E
 	return nil
 }
 
+// ExpiredErrorTags returns the errortag documents of every index saved
+// before cutoff, or without a date
+func (persist *persistence) ExpiredErrorTags(cutoff int64, size int) ([]EsIndex, gobol.Error) {
+
+	start := time.Now()
+
+	query := EsErrorQuery{
+		Size: size,
+		Query: map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{
+						"range": map[string]interface{}{
+							"date": map[string]int64{"lt": cutoff},
+						},
+					},
+					map[string]interface{}{
+						"bool": map[string]interface{}{
+							"must_not": map[string]interface{}{
+								"exists": map[string]string{"field": "date"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var resp EsErrorResponse
+
+	_, err := persist.esearch.Query("_all", "errortag", query, &resp)
+	if err != nil {
+		statsIndexError("_all", "errortag", "POST")
+		return nil, errPersist("ExpiredErrorTags", err)
+	}
+	statsIndex("_all", "errortag", "POST", time.Since(start))
+
+	docs := make([]EsIndex, len(resp.Hits.Hits))
+
+	for i, hit := range resp.Hits.Hits {
+		docs[i] = EsIndex{EsID: hit.ID, EsType: hit.Type, EsIndex: hit.Index}
+	}
+
+	return docs, nil
+}
+
+// errorIndexesMax bounds the indexes counted by CountErrorTags, elasticsearch
+// 5 doesn't take a terms aggregation of size 0 as unbounded
+const errorIndexesMax = 10000
+
+// CountErrorTags returns how many errortag documents each index holds
+func (persist *persistence) CountErrorTags() (map[string]int, gobol.Error) {
+
+	start := time.Now()
+
+	query := EsErrorQuery{
+		Aggs: map[string]interface{}{
+			"indexes": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "_index", "size": errorIndexesMax},
+			},
+		},
+	}
+
+	var resp EsErrorResponse
+
+	_, err := persist.esearch.Query("_all", "errortag", query, &resp)
+	if err != nil {
+		statsIndexError("_all", "errortag", "POST")
+		return nil, errPersist("CountErrorTags", err)
+	}
+	statsIndex("_all", "errortag", "POST", time.Since(start))
+
+	count := map[string]int{}
+
+	for _, b := range resp.Aggregations.Indexes.Buckets {
+		count[b.Key] = b.DocCount
+	}
+
+	return count, nil
+}
+
 func (persist *persistence) SaveBulkES(body io.Reader) gobol.Error {
 	start := time.Now()
 	_, err := persist.esearch.PostBulk(body)
@@ -185,3 +303,256 @@ func (persist *persistence) SaveBulkES(body io.Reader) gobol.Error {
 	statsIndex("", "", "bulk", time.Since(start))
 	return nil
 }
+
+func (persist *persistence) PingES(index string) gobol.Error {
+
+	var resp EsCountResponse
+
+	code, err := persist.esearch.Query(index, "meta", EsCount{}, &resp)
+	if err != nil {
+		return errPersist("PingES", err)
+	}
+
+	if code != http.StatusOK {
+		return errPersist("PingES", fmt.Errorf("elasticsearch returned status %d", code))
+	}
+
+	return nil
+}
+
+func (persist *persistence) InsertDeadLetter(keyspace string, dl DeadLetter, ttl int) gobol.Error {
+	start := time.Now()
+	var err error
+	for _, cons := range persist.consistencies {
+		if err = persist.cassandra.Query(
+			`INSERT INTO ts_deadletter (keyspace, day, id, source, number, metric, tsid, reason, error, message) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) USING TTL ?`,
+			keyspace,
+			deadLetterDay(dl.ID.Time()),
+			dl.ID,
+			dl.Source,
+			dl.Number,
+			dl.Metric,
+			dl.TSID,
+			string(dl.Reason),
+			dl.Error,
+			dl.Payload,
+			ttl,
+		).Consistency(cons).Exec(); err != nil {
+			statsInsertQerror("default", "ts_deadletter")
+			gblog.WithFields(
+				logrus.Fields{
+					"package": "collector/persistence",
+					"func":    "InsertDeadLetter",
+				},
+			).Error(err)
+			continue
+		}
+		statsInsert("default", "ts_deadletter", time.Since(start))
+		return nil
+	}
+	statsInsertFBerror("default", "ts_deadletter")
+	return errPersist("InsertDeadLetter", err)
+}
+
+// SelectDeadLetters returns, newest first, up to limit dead letters of a
+// keyspace day between from and to. When before is set it replaces to, so
+// pages continue after the last dead letter returned
+func (persist *persistence) SelectDeadLetters(
+	keyspace string,
+	day int,
+	from, to time.Time,
+	before *gocql.UUID,
+	reason string,
+	limit int,
+) ([]DeadLetter, gobol.Error) {
+
+	start := time.Now()
+
+	query := `SELECT id, source, number, metric, tsid, reason, error, message FROM ts_deadletter WHERE keyspace = ? AND day = ? AND id >= minTimeuuid(?)`
+	values := []interface{}{keyspace, day, from}
+
+	if before != nil {
+		query += ` AND id < ?`
+		values = append(values, *before)
+	} else {
+		query += ` AND id <= maxTimeuuid(?)`
+		values = append(values, to)
+	}
+
+	if reason != "" {
+		query += ` AND reason = ?`
+		values = append(values, reason)
+	}
+
+	query += ` LIMIT ?`
+	values = append(values, limit)
+
+	if reason != "" {
+		query += ` ALLOW FILTERING`
+	}
+
+	var err error
+
+	for _, cons := range persist.consistencies {
+
+		dls := []DeadLetter{}
+
+		var dl DeadLetter
+		var code string
+
+		iter := persist.cassandra.Query(query, values...).Consistency(cons).Iter()
+
+		for iter.Scan(&dl.ID, &dl.Source, &dl.Number, &dl.Metric, &dl.TSID, &code, &dl.Error, &dl.Payload) {
+			dl.Reason = tserr.Code(code)
+			dls = append(dls, dl)
+		}
+
+		if err = iter.Close(); err != nil {
+			statsQueryError("default", "ts_deadletter", "select")
+			gblog.WithFields(
+				logrus.Fields{
+					"package": "collector/persistence",
+					"func":    "SelectDeadLetters",
+				},
+			).Error(err)
+			continue
+		}
+
+		statsQuery("default", "ts_deadletter", "select", time.Since(start))
+		return dls, nil
+	}
+
+	statsFBerror("default", "ts_deadletter", "select")
+	return nil, errPersist("SelectDeadLetters", err)
+}
+
+// CountDeadLetters counts the dead letters of a keyspace day between from
+// and to by reason
+func (persist *persistence) CountDeadLetters(keyspace string, day int, from, to time.Time) (map[string]int, gobol.Error) {
+
+	start := time.Now()
+
+	var err error
+
+	for _, cons := range persist.consistencies {
+
+		count := map[string]int{}
+
+		var reason string
+
+		iter := persist.cassandra.Query(
+			`SELECT reason FROM ts_deadletter WHERE keyspace = ? AND day = ? AND id >= minTimeuuid(?) AND id <= maxTimeuuid(?)`,
+			keyspace,
+			day,
+			from,
+			to,
+		).Consistency(cons).Iter()
+
+		for iter.Scan(&reason) {
+			count[reason]++
+		}
+
+		if err = iter.Close(); err != nil {
+			statsQueryError("default", "ts_deadletter", "select")
+			gblog.WithFields(
+				logrus.Fields{
+					"package": "collector/persistence",
+					"func":    "CountDeadLetters",
+				},
+			).Error(err)
+			continue
+		}
+
+		statsQuery("default", "ts_deadletter", "select", time.Since(start))
+		return count, nil
+	}
+
+	statsFBerror("default", "ts_deadletter", "select")
+	return nil, errPersist("CountDeadLetters", err)
+}
+
+// GetDeadLetter returns a dead letter by id, nil when it doesn't exist
+func (persist *persistence) GetDeadLetter(keyspace string, id gocql.UUID) (*DeadLetter, gobol.Error) {
+
+	start := time.Now()
+
+	var err error
+
+	for _, cons := range persist.consistencies {
+
+		dl := DeadLetter{ID: id}
+		var code string
+
+		err = persist.cassandra.Query(
+			`SELECT source, number, metric, tsid, reason, error, message FROM ts_deadletter WHERE keyspace = ? AND day = ? AND id = ?`,
+			keyspace,
+			deadLetterDay(id.Time()),
+			id,
+		).Consistency(cons).Scan(&dl.Source, &dl.Number, &dl.Metric, &dl.TSID, &code, &dl.Error, &dl.Payload)
+
+		if err == gocql.ErrNotFound {
+			statsQuery("default", "ts_deadletter", "select", time.Since(start))
+			return nil, nil
+		}
+
+		if err != nil {
+			statsQueryError("default", "ts_deadletter", "select")
+			gblog.WithFields(
+				logrus.Fields{
+					"package": "collector/persistence",
+					"func":    "GetDeadLetter",
+				},
+			).Error(err)
+			continue
+		}
+
+		dl.Reason = tserr.Code(code)
+
+		statsQuery("default", "ts_deadletter", "select", time.Since(start))
+		return &dl, nil
+	}
+
+	statsFBerror("default", "ts_deadletter", "select")
+	return nil, errPersist("GetDeadLetter", err)
+}
+
+// DeleteDeadLetters deletes dead letters of the same keyspace day in a
+// single partition batch
+func (persist *persistence) DeleteDeadLetters(keyspace string, day int, ids []gocql.UUID) gobol.Error {
+
+	start := time.Now()
+
+	var err error
+
+	for _, cons := range persist.consistencies {
+
+		batch := persist.cassandra.NewBatch(gocql.UnloggedBatch)
+		batch.Cons = cons
+
+		for _, id := range ids {
+			batch.Query(
+				`DELETE FROM ts_deadletter WHERE keyspace = ? AND day = ? AND id = ?`,
+				keyspace,
+				day,
+				id,
+			)
+		}
+
+		if err = persist.cassandra.ExecuteBatch(batch); err != nil {
+			statsQueryError("default", "ts_deadletter", "delete")
+			gblog.WithFields(
+				logrus.Fields{
+					"package": "collector/persistence",
+					"func":    "DeleteDeadLetters",
+				},
+			).Error(err)
+			continue
+		}
+
+		statsQuery("default", "ts_deadletter", "delete", time.Since(start))
+		return nil
+	}
+
+	statsFBerror("default", "ts_deadletter", "delete")
+	return errPersist("DeleteDeadLetters", err)
+}
@@ -1,12 +1,21 @@
 package collector
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gocql/gocql"
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/auth"
+	"github.com/uol/mycenae/lib/parser"
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (collect *Collector) Scollector(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -15,7 +24,7 @@ func (collect *Collector) Scollector(w http.ResponseWriter, r *http.Request, ps
 
 	gerr := rip.FromJSON(r, &points)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -23,9 +32,11 @@ func (collect *Collector) Scollector(w http.ResponseWriter, r *http.Request, ps
 
 	restChan := make(chan RestError, len(points))
 
+	principal := auth.FromRequest(r)
+
 	for _, point := range points {
 		collect.concPoints <- struct{}{}
-		go collect.handleRESTpacket(point, true, restChan)
+		go collect.handleRESTpacket(point, true, principal, restChan)
 
 	}
 
@@ -45,6 +56,8 @@ func (collect *Collector) Scollector(w http.ResponseWriter, r *http.Request, ps
 			reu := RestErrorUser{
 				Datapoint: re.Datapoint,
 				Error:     re.Gerr.Message(),
+				Code:      tserr.CodeOf(re.Gerr),
+				status:    re.Gerr.StatusCode(),
 			}
 
 			returnPoints.Errors = append(returnPoints.Errors, reu)
@@ -59,7 +72,7 @@ func (collect *Collector) Scollector(w http.ResponseWriter, r *http.Request, ps
 		returnPoints.Failed = len(returnPoints.Errors)
 		returnPoints.Success = len(points) - len(returnPoints.Errors)
 
-		rip.SuccessJSON(w, http.StatusBadRequest, returnPoints)
+		rip.SuccessJSON(w, restStatus(returnPoints), returnPoints)
 		return
 	}
 
@@ -73,7 +86,7 @@ func (collect *Collector) Text(w http.ResponseWriter, r *http.Request, ps httpro
 
 	gerr := rip.FromJSON(r, &points)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -81,9 +94,11 @@ func (collect *Collector) Text(w http.ResponseWriter, r *http.Request, ps httpro
 
 	restChan := make(chan RestError, len(points))
 
+	principal := auth.FromRequest(r)
+
 	for _, point := range points {
 		collect.concPoints <- struct{}{}
-		go collect.handleRESTpacket(point, false, restChan)
+		go collect.handleRESTpacket(point, false, principal, restChan)
 	}
 
 	var reqKS string
@@ -107,6 +122,8 @@ func (collect *Collector) Text(w http.ResponseWriter, r *http.Request, ps httpro
 			reu := RestErrorUser{
 				Datapoint: re.Datapoint,
 				Error:     re.Gerr.Message(),
+				Code:      tserr.CodeOf(re.Gerr),
+				status:    re.Gerr.StatusCode(),
 			}
 
 			returnPoints.Errors = append(returnPoints.Errors, reu)
@@ -125,7 +142,7 @@ func (collect *Collector) Text(w http.ResponseWriter, r *http.Request, ps httpro
 		returnPoints.Failed = len(returnPoints.Errors)
 		returnPoints.Success = len(points) - len(returnPoints.Errors)
 
-		rip.SuccessJSON(w, http.StatusBadRequest, returnPoints)
+		rip.SuccessJSON(w, restStatus(returnPoints), returnPoints)
 		return
 	}
 
@@ -133,7 +150,25 @@ func (collect *Collector) Text(w http.ResponseWriter, r *http.Request, ps httpro
 	return
 }
 
-func (collect *Collector) handleRESTpacket(rcvMsg TSDBpoint, number bool, restChan chan RestError) {
+// restStatus is 403 when any point was written to a keyspace the token
+// can't write into, or to the reserved one, 429 when any point was rejected by a keyspace quota
+func restStatus(re RestErrors) int {
+
+	status := http.StatusBadRequest
+
+	for _, e := range re.Errors {
+		switch e.status {
+		case http.StatusForbidden:
+			return http.StatusForbidden
+		case http.StatusTooManyRequests:
+			status = http.StatusTooManyRequests
+		}
+	}
+
+	return status
+}
+
+func (collect *Collector) handleRESTpacket(rcvMsg TSDBpoint, number bool, principal *auth.Principal, restChan chan RestError) {
 	recvPoint := rcvMsg
 	var gerr gobol.Error
 	i := 0
@@ -158,8 +193,8 @@ func (collect *Collector) handleRESTpacket(rcvMsg TSDBpoint, number bool, restCh
 
 	if i > 13 {
 		err := errors.New("the maximum resolution suported for timestamp is milliseconds")
-		gerr = errBR("HandleRESTpacket", err.Error(), err)
-	} else {
+		gerr = errTimestamp("HandleRESTpacket", err)
+	} else if gerr = principal.Authorize(rcvMsg.Tags["ksid"], auth.Write); gerr == nil {
 		if number {
 			rcvMsg.Text = ""
 		} else {
@@ -169,6 +204,10 @@ func (collect *Collector) handleRESTpacket(rcvMsg TSDBpoint, number bool, restCh
 		gerr = collect.HandlePacket(rcvMsg, number)
 	}
 
+	if gerr != nil {
+		collect.deadLetterREST(rcvMsg, number, gerr)
+	}
+
 	restChan <- RestError{
 		Datapoint: recvPoint,
 		Gerr:      gerr,
@@ -176,3 +215,226 @@ func (collect *Collector) handleRESTpacket(rcvMsg TSDBpoint, number bool, restCh
 
 	<-collect.concPoints
 }
+
+func (collect *Collector) deadLetterREST(point TSDBpoint, number bool, cause gobol.Error) {
+
+	payload, err := json.Marshal(point)
+	if err != nil {
+		gerr := errMarshal("deadLetterREST", err)
+		gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+		return
+	}
+
+	if gerr := collect.deadLetter(sourceHTTP, number, point, string(payload), cause); gerr != nil {
+		gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+	}
+}
+
+// Ingest returns the points received and the errors of the probe window
+func (collect *Collector) Ingest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	rip.SuccessJSON(w, http.StatusOK, collect.IngestStats())
+}
+
+// ListDeadLetters returns the points rejected in a keyspace, newest first.
+// The next page is requested with the cursor of the response
+func (collect *Collector) ListDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/deadletters", "keyspace": ps.ByName("keyspace")})
+
+	q, gerr := deadLetterQuery(r, ps)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	params := r.URL.Query()
+
+	size := deadLetterPage
+
+	if s := params.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > deadLetterMaxPage {
+			tserr.Fail(w, errDeadLetterParam(
+				"ListDeadLetters",
+				fmt.Sprintf(`query param "size" should be an integer between 1 and %d`, deadLetterMaxPage),
+			))
+			return
+		}
+		size = n
+	}
+
+	var cursor *gocql.UUID
+
+	if c := params.Get("cursor"); c != "" {
+		id, err := gocql.ParseUUID(c)
+		if err != nil {
+			tserr.Fail(w, errDeadLetterParam("ListDeadLetters", `query param "cursor" is invalid`))
+			return
+		}
+		cursor = &id
+	}
+
+	dls, next, gerr := collect.DeadLetters(q, size, cursor)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, DeadLetterPage{DeadLetters: dls, Cursor: next})
+}
+
+// DeadLetterReasons returns how many points were rejected in a keyspace by
+// reason
+func (collect *Collector) DeadLetterReasons(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/deadletters/reasons", "keyspace": ps.ByName("keyspace")})
+
+	q, gerr := deadLetterQuery(r, ps)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	count, gerr := collect.CountDeadLetters(q)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	total := 0
+	for _, n := range count {
+		total += n
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, DeadLetterCount{Total: total, Reasons: count})
+}
+
+// DeleteDeadLetters purges the points rejected in a keyspace
+func (collect *Collector) DeleteDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/deadletters", "keyspace": ps.ByName("keyspace")})
+
+	q, gerr := deadLetterQuery(r, ps)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	purged, gerr := collect.PurgeDeadLetters(q)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, DeadLetterPurge{Purged: purged})
+}
+
+// ReplayDeadLetters sends rejected points of a keyspace through the
+// collector again. The keyspace of the points can be replaced with ksid, the
+// token then needs to write into it
+func (collect *Collector) ReplayDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyspace := ps.ByName("keyspace")
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/deadletters/replay", "keyspace": keyspace})
+
+	req := DeadLetterReplay{}
+
+	gerr := rip.FromJSON(r, &req)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	if req.Ksid != "" {
+		if gerr := auth.FromRequest(r).Authorize(req.Ksid, auth.Write); gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+	}
+
+	resp := DeadLetterReplayed{}
+
+	for _, s := range req.IDs {
+
+		id, err := gocql.ParseUUID(s)
+		if err != nil {
+			gerr = errDeadLetterParam("ReplayDeadLetters", fmt.Sprintf("invalid dead letter id %q", s))
+		} else {
+			gerr = collect.ReplayDeadLetter(keyspace, id, req.Ksid)
+		}
+
+		if gerr != nil {
+			resp.Failed++
+			resp.Errors = append(resp.Errors, DeadLetterError{
+				ID:    s,
+				Error: gerr.Message(),
+				Code:  tserr.CodeOf(gerr),
+			})
+			continue
+		}
+
+		resp.Replayed++
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, resp)
+}
+
+// deadLetterQuery reads the keyspace, the time range and the reason of the
+// dead letters a request is about. The range defaults to the last day
+func deadLetterQuery(r *http.Request, ps httprouter.Params) (DeadLetterQuery, gobol.Error) {
+
+	params := r.URL.Query()
+
+	q := DeadLetterQuery{
+		Keyspace: ps.ByName("keyspace"),
+		End:      time.Now(),
+		Reason:   params.Get("reason"),
+	}
+
+	if s := params.Get("end"); s != "" {
+		end, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return q, errDeadLetterParam("deadLetterQuery", `query param "end" should be a timestamp`)
+		}
+		q.End = deadLetterTime(s, end)
+	}
+
+	q.Start = q.End.Add(-24 * time.Hour)
+
+	if s := params.Get("start"); s != "" {
+		if start, err := strconv.ParseInt(s, 10, 64); err == nil {
+			q.Start = deadLetterTime(s, start)
+		} else if len(s) < 2 {
+			return q, errDeadLetterParam("deadLetterQuery", `query param "start" should be a timestamp or a relative time like 1h`)
+		} else {
+			start, gerr := parser.GetRelativeStart(q.End, s)
+			if gerr != nil {
+				return q, errDeadLetterParam("deadLetterQuery", `query param "start" should be a timestamp or a relative time like 1h`)
+			}
+			q.Start = start
+		}
+	}
+
+	if q.End.Before(q.Start) {
+		return q, errDeadLetterParam("deadLetterQuery", "end date should be equal or bigger than start date")
+	}
+
+	if q.End.Sub(q.Start) > deadLetterMaxRange {
+		return q, errDeadLetterParam(
+			"deadLetterQuery",
+			fmt.Sprintf("the time range can't be longer than %v", deadLetterMaxRange),
+		)
+	}
+
+	return q, nil
+}
+
+// deadLetterTime reads timestamps in seconds or, with more than ten digits,
+// in milliseconds
+func deadLetterTime(s string, ts int64) time.Time {
+	if len(s) <= 10 {
+		return time.Unix(ts, 0)
+	}
+	return time.Unix(0, ts*int64(time.Millisecond))
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// cleanupRounds limits the bulk deletes of a cleanup run, the documents left
+// are deleted by the next runs
+const cleanupRounds = 100
+
+// errorTTL is the cassandra TTL, in seconds, of the rejected points. Zero
+// keeps them forever
+func (collect *Collector) errorTTL() int {
+	return int(collect.retention / time.Second)
+}
+
+// errorCleanup deletes the errortag documents older than the retention and
+// reports how many documents each keyspace holds
+func (collect *Collector) errorCleanup(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if collect.retention > 0 {
+				collect.expireErrorTags()
+			}
+			collect.countErrorTags()
+		case <-collect.stopCleanup:
+			return
+		}
+	}
+}
+
+// expireErrorTags deletes the expired errortag documents of every index.
+// Documents saved before they had a date are expired too
+func (collect *Collector) expireErrorTags() {
+
+	lf := logrus.Fields{
+		"package": "collector",
+		"func":    "expireErrorTags",
+	}
+
+	cutoff := time.Now().Add(-collect.retention).UnixNano() / int64(time.Millisecond)
+
+	deleted := 0
+
+	for i := 0; i < cleanupRounds; i++ {
+
+		select {
+		case <-collect.stopCleanup:
+			return
+		default:
+		}
+
+		docs, gerr := collect.persist.ExpiredErrorTags(cutoff, collect.cleanupBatch)
+		if gerr != nil {
+			gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+			return
+		}
+
+		if len(docs) == 0 {
+			break
+		}
+
+		body := &bytes.Buffer{}
+		enc := json.NewEncoder(body)
+
+		for _, doc := range docs {
+			if err := enc.Encode(BulkDelete{ID: doc}); err != nil {
+				gerr := errMarshal("expireErrorTags", err)
+				gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+				return
+			}
+		}
+
+		if gerr := collect.persist.SaveBulkES(body); gerr != nil {
+			gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+			return
+		}
+
+		deleted += len(docs)
+
+		if len(docs) < collect.cleanupBatch {
+			break
+		}
+	}
+
+	if deleted > 0 {
+		lf["deleted"] = deleted
+		gblog.WithFields(lf).Info("expired errortag documents deleted")
+	}
+}
+
+// countErrorTags sets the errors.stored gauge of every keyspace, keyspaces
+// left without documents are set to zero
+func (collect *Collector) countErrorTags() {
+
+	count, gerr := collect.persist.CountErrorTags()
+	if gerr != nil {
+		gblog.WithFields(gerr.LogFields()).Error(gerr.Error())
+		return
+	}
+
+	for index := range collect.errorIndexes {
+		if _, ok := count[index]; !ok {
+			count[index] = 0
+		}
+	}
+
+	collect.errorIndexes = map[string]bool{}
+
+	for index, n := range count {
+
+		ks := index
+		if index == collect.settings.ElasticSearch.Index {
+			ks = "default"
+		}
+
+		statsErrorsStored(ks, n)
+
+		if n > 0 {
+			collect.errorIndexes[index] = true
+		}
+	}
+}
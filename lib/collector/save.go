@@ -2,11 +2,24 @@ package collector
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
 )
 
+// tokenTag matches the token tag of a raw point, even of one that isn't
+// valid JSON. An unterminated token is matched to the end of the point
+var tokenTag = regexp.MustCompile(`("token"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// redactToken hides the token tag of a rejected point, the errors and dead
+// letters saved are readable by clients that don't hold it
+func redactToken(payload string) string {
+	return tokenTag.ReplaceAllString(payload, `${1}"REDACTED"`)
+}
+
 func (collector *Collector) saveValue(packet Point) gobol.Error {
 	return collector.persist.InsertPoint(
 		packet.KsID,
@@ -49,8 +62,8 @@ func (collector *Collector) saveError(
 	keyspace,
 	esIndex,
 	id,
-	msg,
-	errMsg string,
+	msg string,
+	cause gobol.Error,
 ) gobol.Error {
 
 	now := time.Now()
@@ -62,7 +75,9 @@ func (collector *Collector) saveError(
 
 	idks := fmt.Sprintf("%s%s", id, keyspace)
 
-	gerr := collector.persist.InsertError(idks, msg, errMsg, now)
+	code := tserr.CodeOf(cause)
+
+	gerr := collector.persist.InsertError(idks, redactToken(msg), cause.Error(), code, now, collector.errorTTL())
 	if gerr != nil {
 		return gerr
 	}
@@ -70,6 +85,9 @@ func (collector *Collector) saveError(
 	var tags []Tag
 
 	for k, v := range recvTags {
+		if k == "token" {
+			continue
+		}
 		tag := Tag{
 			Key:   k,
 			Value: v,
@@ -80,6 +98,8 @@ func (collector *Collector) saveError(
 	doc := StructV2Error{
 		Key:    id,
 		Metric: metric,
+		Code:   code,
+		Date:   now.UnixNano() / int64(time.Millisecond),
 		Tags:   tags,
 	}
 
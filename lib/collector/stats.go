@@ -94,6 +94,43 @@ func statsInsert(ks, cf string, d time.Duration) {
 	)
 }
 
+func statsQuery(ks, cf, op string, d time.Duration) {
+	go statsIncrement("cassandra.query", map[string]string{"keyspace": ks, "column_family": cf, "operation": op})
+	go statsValueAdd(
+		"cassandra.query.duration",
+		map[string]string{"keyspace": ks, "column_family": cf, "operation": op},
+		float64(d.Nanoseconds())/float64(time.Millisecond),
+	)
+}
+
+func statsQueryError(ks, cf, op string) {
+	go statsIncrement(
+		"cassandra.query.error",
+		map[string]string{"keyspace": ks, "column_family": cf, "operation": op},
+	)
+}
+
+func statsFBerror(ks, cf, op string) {
+	go statsIncrement(
+		"cassandra.fallback.error",
+		map[string]string{"keyspace": ks, "column_family": cf, "operation": op},
+	)
+}
+
+func statsDeadLetter(ks, source string) {
+	go statsIncrement(
+		"points.deadletter",
+		map[string]string{"keyspace": ks, "source": source},
+	)
+}
+
+func statsDeadLetterDropped(ks, source string) {
+	go statsIncrement(
+		"points.deadletter.dropped",
+		map[string]string{"keyspace": ks, "source": source},
+	)
+}
+
 func statsPoints(ks, vt string) {
 	go statsIncrement(
 		"points.received",
@@ -108,6 +145,10 @@ func statsPointsError(ks, vt string) {
 	)
 }
 
+func statsErrorsStored(ks string, n int) {
+	stats.ValueSet("collector", "errors.stored", map[string]string{"keyspace": ks}, float64(n))
+}
+
 func statsIncrement(metric string, tags map[string]string) {
 	stats.Increment("collector", metric, tags)
 }
@@ -2,7 +2,9 @@ package collector
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
@@ -40,9 +42,80 @@ type RestError struct {
 	Gerr      gobol.Error `json:"error"`
 }
 
+type EsCount struct {
+	Size  int         `json:"size"`
+	Query interface{} `json:"query,omitempty"`
+}
+
+type EsTerm struct {
+	Term map[string]string `json:"term"`
+}
+
+type EsCountResponse struct {
+	Hits EsCountHits `json:"hits"`
+}
+
+type EsCountHits struct {
+	Total int `json:"total"`
+}
+
 type RestErrorUser struct {
 	Datapoint TSDBpoint   `json:"datapoint"`
 	Error     interface{} `json:"error"`
+	Code      tserr.Code  `json:"code"`
+	status    int
+}
+
+type IngestStats struct {
+	Window     string  `json:"window"`
+	Received   int64   `json:"received"`
+	Errors     int64   `json:"errors"`
+	ErrorRatio float64 `json:"errorRatio"`
+	Rate       float64 `json:"pointsPerSecond"`
+}
+
+type DeadLetterPage struct {
+	DeadLetters []DeadLetter `json:"deadLetters"`
+	Cursor      string       `json:"cursor,omitempty"`
+}
+
+type DeadLetterCount struct {
+	Total   int            `json:"total"`
+	Reasons map[string]int `json:"reasons"`
+}
+
+type DeadLetterPurge struct {
+	Purged int `json:"purged"`
+}
+
+type DeadLetterReplay struct {
+	IDs  []string `json:"ids"`
+	Ksid string   `json:"ksid,omitempty"`
+}
+
+func (dr DeadLetterReplay) Validate() gobol.Error {
+	if len(dr.IDs) == 0 {
+		return errDeadLetterParam("DeadLetterReplay", "no dead letter ids")
+	}
+	if len(dr.IDs) > deadLetterMaxPage {
+		return errDeadLetterParam(
+			"DeadLetterReplay",
+			fmt.Sprintf("at most %d dead letters can be replayed at once", deadLetterMaxPage),
+		)
+	}
+	return nil
+}
+
+type DeadLetterReplayed struct {
+	Replayed int               `json:"replayed"`
+	Failed   int               `json:"failed"`
+	Errors   []DeadLetterError `json:"errors,omitempty"`
+}
+
+type DeadLetterError struct {
+	ID    string     `json:"id"`
+	Error string     `json:"error"`
+	Code  tserr.Code `json:"code"`
 }
 
 type RestErrors struct {
@@ -63,9 +136,11 @@ type Point struct {
 }
 
 type StructV2Error struct {
-	Key    string `json:"key"`
-	Metric string `json:"metric"`
-	Tags   []Tag  `json:"tagsError"`
+	Key    string     `json:"key"`
+	Metric string     `json:"metric"`
+	Code   tserr.Code `json:"code"`
+	Date   int64      `json:"date,omitempty"`
+	Tags   []Tag      `json:"tagsError"`
 }
 
 type Tag struct {
@@ -74,9 +149,62 @@ type Tag struct {
 }
 
 type MetaInfo struct {
-	Metric string `json:"metric"`
-	ID     string `json:"id"`
-	Tags   []Tag  `json:"tagsNested"`
+	Metric    string `json:"metric"`
+	ID        string `json:"id"`
+	Tags      []Tag  `json:"tagsNested"`
+	FirstSeen int64  `json:"firstSeen,omitempty"`
+	LastSeen  int64  `json:"lastSeen,omitempty"`
+}
+
+type lastSeen struct {
+	ksid      string
+	esType    string
+	id        string
+	timestamp int64
+	firstSeen int64
+	known     bool
+}
+
+// lsShards is the number of shards of the time ranges of the timeseries, so
+// the ingest workers don't wait on a single lock
+const lsShards = 64
+
+// lsShard holds the time ranges of the timeseries seen, the updates pending
+// and the timeseries admitted by the series quota whose points were not saved
+// yet, with the time they were admitted
+type lsShard struct {
+	sync.Mutex
+	seen     map[string]seenRange
+	pending  map[string]lastSeen
+	admitted map[string]int64
+}
+
+// seenRange is the time range of the points of a timeseries seen by this
+// node. known tells if first is not newer than the firstSeen indexed, it is
+// unknown for the timeseries indexed before the node started
+type seenRange struct {
+	first   int64
+	last    int64
+	known   bool
+	touched int64
+}
+
+type BulkUpdate struct {
+	ID EsIndex `json:"update"`
+}
+
+type EsLastSeen struct {
+	Doc struct {
+		FirstSeen int64 `json:"firstSeen,omitempty"`
+		LastSeen  int64 `json:"lastSeen,omitempty"`
+	} `json:"doc"`
+}
+
+type EsMetaSeen struct {
+	Found  bool `json:"found"`
+	Source struct {
+		FirstSeen int64 `json:"firstSeen"`
+	} `json:"_source"`
 }
 
 type LogMeta struct {
@@ -84,12 +212,41 @@ type LogMeta struct {
 	Meta   MetaInfo `json:"meta"`
 }
 
+type EsErrorQuery struct {
+	Size   int                    `json:"size"`
+	Source bool                   `json:"_source"`
+	Query  map[string]interface{} `json:"query,omitempty"`
+	Aggs   map[string]interface{} `json:"aggs,omitempty"`
+}
+
+type EsErrorResponse struct {
+	Hits struct {
+		Hits []struct {
+			Index string `json:"_index"`
+			Type  string `json:"_type"`
+			ID    string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations struct {
+		Indexes struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int    `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"indexes"`
+	} `json:"aggregations"`
+}
+
 type EsIndex struct {
 	EsID    string `json:"_id"`
 	EsType  string `json:"_type"`
 	EsIndex string `json:"_index"`
 }
 
+type BulkDelete struct {
+	ID EsIndex `json:"delete"`
+}
+
 type BulkType struct {
 	ID EsIndex `json:"index"`
 }
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -8,12 +9,54 @@ import (
 	"github.com/uol/gobol"
 )
 
+// HandleUDPpacket handles a datagram with a single point, a JSON array of
+// points or newline delimited points. Every point is handled on its own, a
+// bad point doesn't discard the rest of the datagram
 func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
-	go func() {
-		collector.saveMutex.Lock()
-		collector.saving++
-		collector.saveMutex.Unlock()
-	}()
+	for _, point := range splitDatagram(buf) {
+		collector.handleUDPpoint(point, addr)
+	}
+}
+
+// splitDatagram returns the raw points of a datagram. Arrays that are not
+// valid JSON, and so can't be split, are returned whole so the error is saved
+func splitDatagram(buf []byte) [][]byte {
+
+	buf = bytes.TrimSpace(buf)
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if buf[0] == '[' {
+		points := []json.RawMessage{}
+		if err := json.Unmarshal(buf, &points); err != nil {
+			return [][]byte{buf}
+		}
+		raw := make([][]byte, len(points))
+		for i, p := range points {
+			raw[i] = p
+		}
+		return raw
+	}
+
+	if json.Valid(buf) {
+		return [][]byte{buf}
+	}
+
+	raw := [][]byte{}
+
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			raw = append(raw, line)
+		}
+	}
+
+	return raw
+}
+
+func (collector *Collector) handleUDPpoint(buf []byte, addr string) {
 
 	rcvMsg := TSDBpoint{}
 
@@ -30,9 +73,13 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 			collector.settings.ElasticSearch.Index,
 			"noKey",
 			string(buf),
-			gerr.Error(),
+			gerr,
 		); gr != nil {
-			gerr = gr
+			collector.fail(gr, addr)
+		}
+
+		if gr := collector.deadLetter(sourceUDP, true, TSDBpoint{}, string(buf), gerr); gr != nil {
+			collector.fail(gr, addr)
 		}
 
 		collector.fail(gerr, addr)
@@ -47,8 +94,20 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 
 	isNumber := true
 
-	gerr = collector.HandlePacket(rcvMsg, isNumber)
+	if collector.auth.UDPtoken() {
+		token := rcvMsg.Tags["token"]
+		delete(rcvMsg.Tags, "token")
+
+		if b, err := json.Marshal(rcvMsg); err == nil {
+			buf = b
+		}
+
+		gerr = collector.auth.AuthorizeUDP(token, msgKs)
+	}
+
 	if gerr != nil {
+		collector.fail(gerr, addr)
+	} else if gerr = collector.HandlePacket(rcvMsg, isNumber); gerr != nil {
 
 		collector.fail(gerr, addr)
 
@@ -71,6 +130,10 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 			id = fmt.Sprintf("T%v", id)
 		}
 
+		if gr := collector.deadLetter(sourceUDP, isNumber, rcvMsg, string(buf), gerr); gr != nil {
+			collector.fail(gr, addr)
+		}
+
 		gerr = collector.saveError(
 			rcvMsg.Tags,
 			rcvMsg.Metric,
@@ -78,7 +141,7 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 			esIndex,
 			id,
 			string(buf),
-			gerr.Error(),
+			gerr,
 		)
 		if gerr != nil {
 			collector.fail(gerr, addr)
@@ -88,11 +151,6 @@ func (collector *Collector) HandleUDPpacket(buf []byte, addr string) {
 		statsUDP(msgKs, "number")
 	}
 
-	go func() {
-		collector.saveMutex.Lock()
-		collector.saving--
-		collector.saveMutex.Unlock()
-	}()
 }
 
 func (collector *Collector) fail(gerr gobol.Error, addr string) {
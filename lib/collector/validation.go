@@ -6,32 +6,35 @@ import (
 
 	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (collector *Collector) makePacket(packet *Point, rcvMsg TSDBpoint, number bool) gobol.Error {
 
 	if number {
 		if rcvMsg.Value == nil {
-			return errValidation(`Wrong Format: Field "value" is required. NO information will be saved`)
+			return errValidation(tserr.InvalidValue, `Wrong Format: Field "value" is required. NO information will be saved`)
 		}
 	} else {
 		if rcvMsg.Text == "" {
-			return errValidation(`Wrong Format: Field "text" is required. NO information will be saved`)
+			return errValidation(tserr.InvalidValue, `Wrong Format: Field "text" is required. NO information will be saved`)
 		}
 
 		if len(rcvMsg.Text) > 10000 {
-			return errValidation(`Wrong Format: Field "text" can not have more than 10k`)
+			return errValidation(tserr.InvalidValue, `Wrong Format: Field "text" can not have more than 10k`)
 		}
 	}
 
 	lt := len(rcvMsg.Tags)
 
 	if lt == 0 {
-		return errValidation(`Wrong Format: At least one tag is required. NO information will be saved`)
+		return errValidation(tserr.MissingTag, `Wrong Format: At least one tag is required. NO information will be saved`)
 	}
 
 	if !collector.validKey.MatchString(rcvMsg.Metric) {
 		return errValidation(
+			tserr.InvalidMetric,
 			fmt.Sprintf(
 				`Wrong Format: Field "metric" (%s) is not well formed. NO information will be saved`,
 				rcvMsg.Metric,
@@ -40,31 +43,46 @@ func (collector *Collector) makePacket(packet *Point, rcvMsg TSDBpoint, number b
 	}
 
 	if ksid, ok := rcvMsg.Tags["ksid"]; !ok {
-		return errValidation(`Wrong Format: Tag "ksid" is required. NO information will be saved`)
+		return errValidation(tserr.MissingKeyspace, `Wrong Format: Tag "ksid" is required. NO information will be saved`)
 	} else if ksid == collector.settings.Cassandra.Keyspace {
-		return errValidation(
-			fmt.Sprintf(
-				`Wrong Format: Keyspace "%s" can not be used. NO information will be saved`,
-				collector.settings.Cassandra.Keyspace,
-			),
-		)
+		return errReservedKeyspace(fmt.Sprintf(
+			`Keyspace "%s" is reserved and can not be written. NO information will be saved`,
+			collector.settings.Cassandra.Keyspace,
+		))
 	} else {
 		packet.KsID = ksid
 	}
 
 	if lt == 1 {
-		return errValidation(`Wrong Format: At least one tag other than "ksid" is required. NO information will be saved`)
+		return errValidation(tserr.MissingTag, `Wrong Format: At least one tag other than "ksid" is required. NO information will be saved`)
 	}
 
 	if lt == 2 {
 		if _, ok := rcvMsg.Tags["ttl"]; ok {
-			return errValidation(`Wrong Format: At least one tag other than "ksid" and "ttl" is required. NO information will be saved`)
+			return errValidation(tserr.MissingTag, `Wrong Format: At least one tag other than "ksid" and "ttl" is required. NO information will be saved`)
+		}
+	}
+
+	if limit := collector.settings.MaxTags; limit > 0 {
+		nt := lt - 1
+		if _, ok := rcvMsg.Tags["ttl"]; ok {
+			nt--
+		}
+		if nt > limit {
+			return errValidation(
+				tserr.TagLimit,
+				fmt.Sprintf(
+					`Wrong Format: A point can not have more than %d tags other than "ksid" and "ttl". NO information will be saved`,
+					limit,
+				),
+			)
 		}
 	}
 
 	for k, v := range rcvMsg.Tags {
 		if !collector.validKey.MatchString(k) {
 			return errValidation(
+				tserr.InvalidTag,
 				fmt.Sprintf(
 					`Wrong Format: Tag key (%s) is not well formed. NO information will be saved`,
 					k,
@@ -73,6 +91,7 @@ func (collector *Collector) makePacket(packet *Point, rcvMsg TSDBpoint, number b
 		}
 		if !collector.validKey.MatchString(v) {
 			return errValidation(
+				tserr.InvalidTag,
 				fmt.Sprintf(
 					`Wrong Format: Tag value (%s) is not well formed. NO information will be saved`,
 					v,
@@ -83,7 +102,7 @@ func (collector *Collector) makePacket(packet *Point, rcvMsg TSDBpoint, number b
 
 	strTUUID, found, gerr := collector.boltc.GetKeyspace(packet.KsID)
 	if !found {
-		return errValidation(`Keyspace not found`)
+		return errValidation(tserr.UnknownKeyspace, `Keyspace not found`)
 	}
 	if gerr != nil {
 		return gerr
@@ -110,5 +129,9 @@ func (collector *Collector) makePacket(packet *Point, rcvMsg TSDBpoint, number b
 	year, week := time.Unix(0, packet.Timestamp*1e+6).ISOWeek()
 	packet.Bucket = fmt.Sprintf("%v%v", year, week)
 
+	if collector.limiter.HasSeriesQuota(packet.KsID) {
+		return collector.allowSeries(*packet)
+	}
+
 	return nil
 }
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// window counts the points received and the errors of the last seconds in
+// a ring of one second buckets. Counting and reading don't take locks,
+// reading doesn't change the counters
+type window struct {
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	second   int64
+	received int64
+	errors   int64
+}
+
+// rotating marks a bucket being reset for a new second
+const rotating = -1
+
+func newWindow(d time.Duration) *window {
+
+	size := int(d / time.Second)
+	if size < 1 {
+		size = 1
+	}
+
+	return &window{buckets: make([]windowBucket, size)}
+}
+
+func (w *window) size() time.Duration {
+	return time.Duration(len(w.buckets)) * time.Second
+}
+
+// bucket returns the bucket of a second, resetting it when it still holds
+// an older second. Only the goroutine that resets it waits for the others
+func (w *window) bucket(sec int64) *windowBucket {
+
+	b := &w.buckets[sec%int64(len(w.buckets))]
+
+	for {
+		s := atomic.LoadInt64(&b.second)
+
+		switch {
+		case s == sec:
+			return b
+		case s == rotating:
+			runtime.Gosched()
+		case s > sec:
+			// the clock went back, count it in the newest second
+			return b
+		case atomic.CompareAndSwapInt64(&b.second, s, rotating):
+			atomic.StoreInt64(&b.received, 0)
+			atomic.StoreInt64(&b.errors, 0)
+			atomic.StoreInt64(&b.second, sec)
+			return b
+		}
+	}
+}
+
+func (w *window) received() {
+	atomic.AddInt64(&w.bucket(time.Now().Unix()).received, 1)
+}
+
+func (w *window) failed() {
+	atomic.AddInt64(&w.bucket(time.Now().Unix()).errors, 1)
+}
+
+// sum returns the points received and the errors of the window
+func (w *window) sum() (received, errors int64) {
+
+	now := time.Now().Unix()
+	oldest := now - int64(len(w.buckets))
+
+	for i := range w.buckets {
+		b := &w.buckets[i]
+
+		s := atomic.LoadInt64(&b.second)
+		if s <= oldest || s > now {
+			continue
+		}
+
+		r := atomic.LoadInt64(&b.received)
+		e := atomic.LoadInt64(&b.errors)
+
+		if atomic.LoadInt64(&b.second) != s {
+			continue
+		}
+
+		received += r
+		errors += e
+	}
+
+	return received, errors
+}
@@ -18,18 +18,38 @@ func GetAggregators() []string {
 func GetFilters() []string {
 	return []string{
 		"literal_or",
+		"iliteral_or",
 		"not_literal_or",
+		"not_iliteral_or",
 		"wildcard",
+		"iwildcard",
 		"regexp",
+		"not_key",
 	}
 }
 
 func GetFiltersFull() map[string]TSDBfilterInfo {
 	return map[string]TSDBfilterInfo{
 		"literal_or": {
+			Examples:    `host=literal_or(web01),  host=literal_or(web01|web02|web03)  {\"type\":\"literal_or\",\"tagk\":\"host\",\"filter\":\"web01|web02|web03\",\"groupBy\":false}`,
+			Description: `Accepts one or more exact values and matches if the series contains any of them. Multiple values can be included and must be separated by the | (pipe) character. The filter is case sensitive and will not allow characters that TSDB does not allow at write time.`,
+		},
+		"iliteral_or": {
 			Examples:    `host=iliteral_or(web01),  host=iliteral_or(web01|web02|web03)  {\"type\":\"iliteral_or\",\"tagk\":\"host\",\"filter\":\"web01|web02|web03\",\"groupBy\":false}`,
 			Description: `Accepts one or more exact values and matches if the series contains any of them. Multiple values can be included and must be separated by the | (pipe) character. The filter is case insensitive and will not allow characters that TSDB does not allow at write time.`,
 		},
+		"not_iliteral_or": {
+			Examples:    `host=not_iliteral_or(web01),  host=not_iliteral_or(web01|web02|web03)  {\"type\":\"not_iliteral_or\",\"tagk\":\"host\",\"filter\":\"web01|web02|web03\",\"groupBy\":false}`,
+			Description: `Accepts one or more exact values and matches if the series does NOT contain any of them. Multiple values can be included and must be separated by the | (pipe) character. The filter is case insensitive and will not allow characters that TSDB does not allow at write time.`,
+		},
+		"iwildcard": {
+			Examples:    `host=iwildcard(web*),  host=iwildcard(WeB*.tsdb.net)  {\"type\":\"iwildcard\",\"tagk\":\"host\",\"filter\":\"WeB*.tsdb.net\",\"groupBy\":false}`,
+			Description: `Performs pre, post and in-fix glob matching of values. The globs are case insensitive and multiple wildcards can be used. The wildcard character is the * (asterisk). At least one wildcard must be present in the filter value. A wildcard by itself can be used as well to match on any value for the tag key.`,
+		},
+		"not_key": {
+			Examples:    `host=not_key()  {\"type\":\"not_key\",\"tagk\":\"host\",\"filter\":\"\",\"groupBy\":false}`,
+			Description: `Skips any time series with the given tag key, regardless of the value. This can be useful for situations where a metric has inconsistent tag sets. NOTE: The filter value must be null or an empty string.`,
+		},
 		"not_literal_or": {
 			Examples:    `host=not_literal_or(web01),  host=not_literal_or(web01|web02|web03)  {\"type\":\"not_literal_or\",\"tagk\":\"host\",\"filter\":\"web01|web02|web03\",\"groupBy\":false}`,
 			Description: `Accepts one or more exact values and matches if the series does NOT contain any of them. Multiple values can be included and must be separated by the | (pipe) character. The filter is case sensitive and will not allow characters that TSDB does not allow at write time.`,
@@ -24,6 +24,19 @@ func errBasic(f, s string, code int, e error) gobol.Error {
 	return nil
 }
 
+func errCode(f, s string, code tserr.Code, httpCode int, e error) gobol.Error {
+	return tserr.NewCode(
+		e,
+		s,
+		code,
+		httpCode,
+		map[string]interface{}{
+			"package": "keyspace",
+			"func":    f,
+		},
+	)
+}
+
 func errConflict(f, s string) gobol.Error {
 	return errBasic(f, s, http.StatusConflict, errors.New(s))
 }
@@ -33,7 +46,7 @@ func errValidationS(f, s string) gobol.Error {
 }
 
 func errNotFound(f string) gobol.Error {
-	return errBasic(f, "", http.StatusNotFound, errors.New(""))
+	return errCode(f, "", tserr.UnknownKeyspace, http.StatusNotFound, errors.New(""))
 }
 
 func errNoContent(f string) gobol.Error {
@@ -41,5 +54,5 @@ func errNoContent(f string) gobol.Error {
 }
 
 func errPersist(f string, e error) gobol.Error {
-	return errBasic(f, e.Error(), http.StatusInternalServerError, e)
+	return errCode(f, e.Error(), tserr.StorageUnavailable, http.StatusInternalServerError, e)
 }
@@ -1,6 +1,7 @@
 package keyspace
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -180,3 +181,9 @@ func (keyspace Keyspace) GetKeyspace(key string) (Config, bool, gobol.Error) {
 func (keyspace Keyspace) listDatacenters() ([]string, gobol.Error) {
 	return keyspace.persist.listDatacenters()
 }
+
+// Ping reads the keyspace table with a consistency, telling if cassandra
+// can serve it
+func (keyspace *Keyspace) Ping(ctx context.Context, cons gocql.Consistency) gobol.Error {
+	return keyspace.persist.ping(ctx, cons)
+}
@@ -2,6 +2,7 @@ package keyspace
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"time"
 
@@ -170,7 +171,7 @@ func (persist *persistence) createKeyspaceMeta(ksc Config, key string) gobol.Err
 
 	if err := persist.cassandra.Query(
 		fmt.Sprintf(
-			`INSERT INTO %s.ts_keyspace (key, name, contact, replication_factor, datacenter, ks_ttl, ks_tuuid) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			`INSERT INTO %s.ts_keyspace (key, name, contact, replication_factor, datacenter, ks_ttl, ks_tuuid, quota_points, quota_series, quota_query_series, quota_queries, quota_max_series, quota_metric_series) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			persist.keyspaceMain,
 		),
 		key,
@@ -180,6 +181,12 @@ func (persist *persistence) createKeyspaceMeta(ksc Config, key string) gobol.Err
 		ksc.Datacenter,
 		ksc.TTL,
 		ksc.TUUID,
+		ksc.Quota.PointsPerSecond,
+		ksc.Quota.NewSeriesPerHour,
+		ksc.Quota.MaxQuerySeries,
+		ksc.Quota.ConcurrentQueries,
+		ksc.Quota.MaxSeries,
+		ksc.Quota.MaxSeriesPerMetric,
 	).Exec(); err != nil {
 		statsQueryError(persist.keyspaceMain, "ts_keyspace", "insert")
 		return errPersist("CreateKeyspaceMeta", err)
@@ -202,6 +209,25 @@ func (persist *persistence) updateKeyspace(ksc ConfigUpdate, key string) gobol.E
 		return errPersist("UpdateKeyspace", err)
 	}
 
+	if ksc.Quota != nil {
+		if err := persist.cassandra.Query(
+			fmt.Sprintf(
+				`UPDATE %s.ts_keyspace SET quota_points = ?, quota_series = ?, quota_query_series = ?, quota_queries = ?, quota_max_series = ?, quota_metric_series = ? WHERE key = ?`,
+				persist.keyspaceMain,
+			),
+			ksc.Quota.PointsPerSecond,
+			ksc.Quota.NewSeriesPerHour,
+			ksc.Quota.MaxQuerySeries,
+			ksc.Quota.ConcurrentQueries,
+			ksc.Quota.MaxSeries,
+			ksc.Quota.MaxSeriesPerMetric,
+			key,
+		).Exec(); err != nil {
+			statsQueryError(persist.keyspaceMain, "ts_keyspace", "update")
+			return errPersist("UpdateKeyspace", err)
+		}
+	}
+
 	statsQuery(persist.keyspaceMain, "ts_keyspace", "update", time.Since(start))
 	return nil
 }
@@ -319,14 +345,27 @@ func (persist *persistence) getKeyspace(key string) (Config, bool, gobol.Error)
 	var name, datacenter string
 	var replication, ttl int
 	var tuuid bool
+	var quota Quota
 
 	if err := persist.cassandra.Query(
 		fmt.Sprintf(
-			`SELECT name, datacenter, replication_factor, ks_ttl, ks_tuuid FROM %s.ts_keyspace WHERE key = ?`,
+			`SELECT name, datacenter, replication_factor, ks_ttl, ks_tuuid, quota_points, quota_series, quota_query_series, quota_queries, quota_max_series, quota_metric_series FROM %s.ts_keyspace WHERE key = ?`,
 			persist.keyspaceMain,
 		),
 		key,
-	).Scan(&name, &datacenter, &replication, &ttl, &tuuid); err != nil {
+	).Scan(
+		&name,
+		&datacenter,
+		&replication,
+		&ttl,
+		&tuuid,
+		&quota.PointsPerSecond,
+		&quota.NewSeriesPerHour,
+		&quota.MaxQuerySeries,
+		&quota.ConcurrentQueries,
+		&quota.MaxSeries,
+		&quota.MaxSeriesPerMetric,
+	); err != nil {
 
 		if err == gocql.ErrNotFound {
 			statsQuery(persist.keyspaceMain, "ts_keyspace", "select", time.Since(start))
@@ -345,6 +384,7 @@ func (persist *persistence) getKeyspace(key string) (Config, bool, gobol.Error)
 		ReplicationFactor: replication,
 		TTL:               ttl,
 		TUUID:             tuuid,
+		Quota:             quota,
 	}, true, nil
 }
 
@@ -384,7 +424,7 @@ func (persist *persistence) listAllKeyspaces() ([]Config, gobol.Error) {
 
 	iter := persist.cassandra.Query(
 		fmt.Sprintf(
-			`SELECT key, name, contact, datacenter, replication_factor, ks_ttl, ks_tuuid FROM %s.ts_keyspace`,
+			`SELECT key, name, contact, datacenter, replication_factor, ks_ttl, ks_tuuid, quota_points, quota_series, quota_query_series, quota_queries, quota_max_series, quota_metric_series FROM %s.ts_keyspace`,
 			persist.keyspaceMain,
 		),
 	).Iter()
@@ -392,10 +432,25 @@ func (persist *persistence) listAllKeyspaces() ([]Config, gobol.Error) {
 	var key, name, contact, datacenter string
 	var replication, ttl int
 	var tuuid bool
+	var quota Quota
 
 	keyspaces := []Config{}
 
-	for iter.Scan(&key, &name, &contact, &datacenter, &replication, &ttl, &tuuid) {
+	for iter.Scan(
+		&key,
+		&name,
+		&contact,
+		&datacenter,
+		&replication,
+		&ttl,
+		&tuuid,
+		&quota.PointsPerSecond,
+		&quota.NewSeriesPerHour,
+		&quota.MaxQuerySeries,
+		&quota.ConcurrentQueries,
+		&quota.MaxSeries,
+		&quota.MaxSeriesPerMetric,
+	) {
 
 		keyspaceMsg := Config{
 			Key:               key,
@@ -405,6 +460,7 @@ func (persist *persistence) listAllKeyspaces() ([]Config, gobol.Error) {
 			ReplicationFactor: replication,
 			TTL:               ttl,
 			TUUID:             tuuid,
+			Quota:             quota,
 		}
 		if keyspaceMsg.Key != persist.keyspaceMain {
 			keyspaces = append(keyspaces, keyspaceMsg)
@@ -458,7 +514,7 @@ func (persist *persistence) createIndex(esIndex string) gobol.Error {
 	body := &bytes.Buffer{}
 
 	body.WriteString(
-		`{"mappings":{"meta":{"properties":{"tagsNested":{"type":"nested","properties":{"tagKey":{"type":"string"},"tagValue":{"type":"string"}}}}},"metatext":{"properties":{"tagsNested":{"type":"nested","properties":{"tagKey":{"type":"string"},"tagValue":{"type":"string"}}}}}}}`,
+		`{"mappings":{"meta":{"properties":{"firstSeen":{"type":"long"},"lastSeen":{"type":"long"},"metric":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}},"tagsNested":{"type":"nested","properties":{"tagKey":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}},"tagValue":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}}}}}},"metatext":{"properties":{"firstSeen":{"type":"long"},"lastSeen":{"type":"long"},"metric":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}},"tagsNested":{"type":"nested","properties":{"tagKey":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}},"tagValue":{"type":"string","fields":{"raw":{"type":"string","index":"not_analyzed"}}}}}}},"errortag":{"properties":{"date":{"type":"long"}}}}}`,
 	)
 
 	_, err := persist.esearch.CreateIndex(esIndex, body)
@@ -483,3 +539,17 @@ func (persist *persistence) deleteIndex(esIndex string) gobol.Error {
 	statsIndex(esIndex, "", "delete", time.Since(start))
 	return nil
 }
+
+func (persist *persistence) ping(ctx context.Context, cons gocql.Consistency) gobol.Error {
+
+	var key string
+
+	err := persist.cassandra.Query(
+		fmt.Sprintf(`SELECT key FROM %s.ts_keyspace LIMIT 1`, persist.keyspaceMain),
+	).Consistency(cons).WithContext(ctx).Scan(&key)
+	if err != nil && err != gocql.ErrNotFound {
+		return errPersist("Ping", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (kspace *Keyspace) Create(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -12,13 +14,13 @@ func (kspace *Keyspace) Create(w http.ResponseWriter, r *http.Request, ps httpro
 	ks := ps.ByName("keyspace")
 	if ks == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Create"))
+		tserr.Fail(w, errNotFound("Create"))
 		return
 	}
 
 	if !validKey.MatchString(ks) {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace"})
-		rip.Fail(w, errValidationS(
+		tserr.Fail(w, errValidationS(
 			"CreateKeyspace",
 			`Wrong Format: Field "keyspaceName" is not well formed. NO information will be saved`,
 		))
@@ -31,7 +33,7 @@ func (kspace *Keyspace) Create(w http.ResponseWriter, r *http.Request, ps httpro
 
 	gerr := rip.FromJSON(r, &ksc)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -39,7 +41,7 @@ func (kspace *Keyspace) Create(w http.ResponseWriter, r *http.Request, ps httpro
 
 	keyspaceKey, gerr := kspace.createKeyspace(ksc)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -56,7 +58,7 @@ func (kspace *Keyspace) Update(w http.ResponseWriter, r *http.Request, ps httpro
 	ks := ps.ByName("keyspace")
 	if ks == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Update"))
+		tserr.Fail(w, errNotFound("Update"))
 		return
 	}
 
@@ -65,14 +67,14 @@ func (kspace *Keyspace) Update(w http.ResponseWriter, r *http.Request, ps httpro
 	gerr := rip.FromJSON(r, &ksc)
 	if gerr != nil {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace"})
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	gerr = kspace.updateKeyspace(ksc, ks)
 	if gerr != nil {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace"})
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -86,12 +88,12 @@ func (kspace *Keyspace) GetAll(w http.ResponseWriter, r *http.Request, ps httpro
 
 	keyspaces, total, gerr := kspace.listAllKeyspaces()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(keyspaces) == 0 {
 		gerr := errNoContent("ListAllKeyspaces")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -109,14 +111,14 @@ func (kspace *Keyspace) Check(w http.ResponseWriter, r *http.Request, ps httprou
 	ks := ps.ByName("keyspace")
 	if ks == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Check"))
+		tserr.Fail(w, errNotFound("Check"))
 		return
 	}
 
 	gerr := kspace.checkKeyspace(ks)
 	if gerr != nil {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace"})
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -130,12 +132,12 @@ func (kspace *Keyspace) ListDC(w http.ResponseWriter, r *http.Request, ps httpro
 
 	datacenters, gerr := kspace.listDatacenters()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(datacenters) == 0 {
 		gerr := errNoContent("ListDatacenters")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -15,6 +15,27 @@ type Config struct {
 	Contact           string `json:"contact"`
 	TTL               int    `json:"ttl"`
 	TUUID             bool   `json:"tuuid"`
+	Quota             Quota  `json:"quota"`
+}
+
+// Quota limits the resources a keyspace can use, zero means unlimited
+type Quota struct {
+	PointsPerSecond    int `json:"pointsPerSecond"`
+	NewSeriesPerHour   int `json:"newSeriesPerHour"`
+	MaxQuerySeries     int `json:"maxQuerySeries"`
+	ConcurrentQueries  int `json:"concurrentQueries"`
+	MaxSeries          int `json:"maxSeries"`
+	MaxSeriesPerMetric int `json:"maxSeriesPerMetric"`
+}
+
+func (q Quota) validate(f string) gobol.Error {
+
+	if q.PointsPerSecond < 0 || q.NewSeriesPerHour < 0 || q.MaxQuerySeries < 0 || q.ConcurrentQueries < 0 ||
+		q.MaxSeries < 0 || q.MaxSeriesPerMetric < 0 {
+		return errValidationS(f, "Quota values can not be negative")
+	}
+
+	return nil
 }
 
 func (c *Config) Validate() gobol.Error {
@@ -42,7 +63,7 @@ func (c *Config) Validate() gobol.Error {
 		return errValidationS("CreateKeyspace", fmt.Sprintf(`Max TTL allowed is %v`, maxTTL))
 	}
 
-	return nil
+	return c.Quota.validate("CreateKeyspace")
 }
 
 func (c *ConfigUpdate) Validate() gobol.Error {
@@ -58,12 +79,17 @@ func (c *ConfigUpdate) Validate() gobol.Error {
 		)
 	}
 
+	if c.Quota != nil {
+		return c.Quota.validate("UpdateKeyspace")
+	}
+
 	return nil
 }
 
 type ConfigUpdate struct {
 	Name    string `json:"name"`
 	Contact string `json:"contact"`
+	Quota   *Quota `json:"quota,omitempty"`
 }
 
 type CreateResponse struct {
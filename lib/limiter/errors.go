@@ -0,0 +1,46 @@
+package limiter
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+func errBasic(f, s string, code int, e error) gobol.Error {
+	if e != nil {
+		return tserr.New(
+			e,
+			s,
+			code,
+			map[string]interface{}{
+				"package": "limiter",
+				"func":    f,
+			},
+		)
+	}
+	return nil
+}
+
+func errInit(s string) gobol.Error {
+	return errBasic("New", s, http.StatusInternalServerError, errors.New(s))
+}
+
+func errQuota(f, s string) gobol.Error {
+	return errBasic(f, s, http.StatusTooManyRequests, errors.New(s))
+}
+
+func errQuerySize(f, s string) gobol.Error {
+	return tserr.NewCode(
+		errors.New(s),
+		s,
+		tserr.QueryTooLarge,
+		http.StatusTooManyRequests,
+		map[string]interface{}{
+			"package": "limiter",
+			"func":    f,
+		},
+	)
+}
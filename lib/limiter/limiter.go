@@ -0,0 +1,332 @@
+package limiter
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/keyspace"
+	"github.com/uol/mycenae/lib/tsstats"
+)
+
+var (
+	gblog *logrus.Logger
+	stats *tsstats.StatsTS
+)
+
+// New creates a limiter that enforces the quotas stored in ts_keyspace.
+// Quotas are cached and read again from cassandra every refresh interval
+func New(gbl *logrus.Logger, sts *tsstats.StatsTS, ks *keyspace.Keyspace, refresh string) (*Limiter, gobol.Error) {
+
+	gblog = gbl
+	stats = sts
+
+	d := time.Minute
+
+	if refresh != "" {
+		var err error
+		d, err = time.ParseDuration(refresh)
+		if err != nil {
+			return nil, errInit("QuotaRefreshInterval needs to be a valid duration")
+		}
+	}
+
+	return &Limiter{
+		kspace:    ks,
+		refresh:   d,
+		keyspaces: map[string]*limits{},
+	}, nil
+}
+
+// Limiter keeps the usage of every keyspace seen by this node. Limits are
+// local to the node
+type Limiter struct {
+	kspace    *keyspace.Keyspace
+	refresh   time.Duration
+	mtx       sync.Mutex
+	keyspaces map[string]*limits
+}
+
+type limits struct {
+	quota   keyspace.Quota
+	loaded  time.Time
+	points  bucket
+	series  bucket
+	queries int
+	counts  map[string]*seriesCount
+}
+
+// seriesCount caches the number of series of a keyspace, or of one of its
+// metrics, incremented locally for every series accepted
+type seriesCount struct {
+	count  int
+	loaded time.Time
+}
+
+// SeriesCounter returns the number of series indexed for a keyspace, or only
+// for a metric when it is not empty
+type SeriesCounter func(ksid, metric string) (int, gobol.Error)
+
+// bucket is a token bucket that is refilled continuously at rate tokens per
+// second up to size tokens
+type bucket struct {
+	rate   float64
+	size   float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(size int, per time.Duration) bucket {
+	return bucket{
+		rate:   float64(size) / per.Seconds(),
+		size:   float64(size),
+		tokens: float64(size),
+		last:   time.Now(),
+	}
+}
+
+func (b *bucket) take(n int, now time.Time) bool {
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.size {
+		b.tokens = b.size
+	}
+	b.last = now
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+
+	return true
+}
+
+// get returns the limits of a keyspace, reading its quota again when it is
+// older than the refresh interval. Quotas that can't be read are kept as
+// they were, keyspaces never read are unlimited
+func (l *Limiter) get(ksid string) *limits {
+
+	l.mtx.Lock()
+
+	lim, ok := l.keyspaces[ksid]
+	if !ok {
+		lim = &limits{counts: map[string]*seriesCount{}}
+		l.keyspaces[ksid] = lim
+	}
+
+	stale := time.Since(lim.loaded) >= l.refresh
+	if stale {
+		lim.loaded = time.Now()
+	}
+
+	l.mtx.Unlock()
+
+	if !stale {
+		return lim
+	}
+
+	ks, _, gerr := l.kspace.GetKeyspace(ksid)
+	if gerr != nil {
+		if gerr.StatusCode() != http.StatusNotFound {
+			gblog.WithFields(logrus.Fields{
+				"package":  "limiter",
+				"func":     "get",
+				"keyspace": ksid,
+			}).Error(gerr.Error())
+		}
+		return lim
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if ks.Quota.PointsPerSecond != lim.quota.PointsPerSecond {
+		lim.points = newBucket(ks.Quota.PointsPerSecond, time.Second)
+	}
+
+	if ks.Quota.NewSeriesPerHour != lim.quota.NewSeriesPerHour {
+		lim.series = newBucket(ks.Quota.NewSeriesPerHour, time.Hour)
+	}
+
+	lim.quota = ks.Quota
+
+	return lim
+}
+
+// AllowPoints checks the points per second quota of a keyspace
+func (l *Limiter) AllowPoints(ksid string, n int) gobol.Error {
+
+	lim := l.get(ksid)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if lim.quota.PointsPerSecond == 0 || lim.points.take(n, time.Now()) {
+		return nil
+	}
+
+	statsQuotaExceeded(ksid, "pointsPerSecond")
+
+	return errQuota(
+		"AllowPoints",
+		fmt.Sprintf("keyspace %s exceeded its quota of %d points per second", ksid, lim.quota.PointsPerSecond),
+	)
+}
+
+// HasSeriesQuota tells if new series of a keyspace need to be checked with
+// AllowSeries
+func (l *Limiter) HasSeriesQuota(ksid string) bool {
+
+	lim := l.get(ksid)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	q := lim.quota
+
+	return q.NewSeriesPerHour > 0 || q.MaxSeries > 0 || q.MaxSeriesPerMetric > 0
+}
+
+// AllowSeries checks the series caps and the new series per hour quota of a
+// keyspace before a new series of metric is created
+func (l *Limiter) AllowSeries(ksid, metric string, counter SeriesCounter) gobol.Error {
+
+	lim := l.get(ksid)
+
+	l.mtx.Lock()
+	q := lim.quota
+	l.mtx.Unlock()
+
+	if q.MaxSeries > 0 {
+		count, gerr := l.count(lim, ksid, "", counter)
+		if gerr == nil && count >= q.MaxSeries {
+			statsQuotaExceeded(ksid, "maxSeries")
+			return errQuota(
+				"AllowSeries",
+				fmt.Sprintf("keyspace %s reached its limit of %d series, new series are rejected", ksid, q.MaxSeries),
+			)
+		}
+	}
+
+	if q.MaxSeriesPerMetric > 0 {
+		count, gerr := l.count(lim, ksid, metric, counter)
+		if gerr == nil && count >= q.MaxSeriesPerMetric {
+			statsQuotaExceeded(ksid, "maxSeriesPerMetric")
+			return errQuota(
+				"AllowSeries",
+				fmt.Sprintf(
+					"metric %s of keyspace %s reached its limit of %d series, new series are rejected",
+					metric,
+					ksid,
+					q.MaxSeriesPerMetric,
+				),
+			)
+		}
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if q.NewSeriesPerHour > 0 && !lim.series.take(1, time.Now()) {
+		statsQuotaExceeded(ksid, "newSeriesPerHour")
+		return errQuota(
+			"AllowSeries",
+			fmt.Sprintf("keyspace %s exceeded its quota of %d new series per hour", ksid, q.NewSeriesPerHour),
+		)
+	}
+
+	for _, k := range []string{"", metric} {
+		if c, ok := lim.counts[k]; ok {
+			c.count++
+		}
+	}
+
+	return nil
+}
+
+// count returns the cached number of series of a keyspace or metric, asking
+// the counter again after the refresh interval. Counting errors are logged
+// and the series is accepted
+func (l *Limiter) count(lim *limits, ksid, metric string, counter SeriesCounter) (int, gobol.Error) {
+
+	l.mtx.Lock()
+	c, ok := lim.counts[metric]
+	if ok && time.Since(c.loaded) < l.refresh {
+		count := c.count
+		l.mtx.Unlock()
+		return count, nil
+	}
+	l.mtx.Unlock()
+
+	count, gerr := counter(ksid, metric)
+	if gerr != nil {
+		gblog.WithFields(logrus.Fields{
+			"package":  "limiter",
+			"func":     "count",
+			"keyspace": ksid,
+			"metric":   metric,
+		}).Error(gerr.Error())
+		return 0, gerr
+	}
+
+	l.mtx.Lock()
+	lim.counts[metric] = &seriesCount{count: count, loaded: time.Now()}
+	l.mtx.Unlock()
+
+	return count, nil
+}
+
+// MaxQuerySeries returns the maximum number of series a query of the
+// keyspace can read, zero means no keyspace limit
+func (l *Limiter) MaxQuerySeries(ksid string) int {
+
+	lim := l.get(ksid)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return lim.quota.MaxQuerySeries
+}
+
+// QuerySeriesExceeded returns the error for a query that matched more series
+// than its keyspace allows
+func (l *Limiter) QuerySeriesExceeded(ksid string, max, total int) gobol.Error {
+
+	statsQuotaExceeded(ksid, "maxQuerySeries")
+
+	return errQuerySize(
+		"QuerySeriesExceeded",
+		fmt.Sprintf("keyspace %s allows %d timeseries per query and the query returned %d", ksid, max, total),
+	)
+}
+
+// AcquireQuery takes a concurrent query slot of a keyspace. The returned
+// function releases it
+func (l *Limiter) AcquireQuery(ksid string) (func(), gobol.Error) {
+
+	lim := l.get(ksid)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if lim.quota.ConcurrentQueries > 0 && lim.queries >= lim.quota.ConcurrentQueries {
+		statsQuotaExceeded(ksid, "concurrentQueries")
+		return nil, errQuota(
+			"AcquireQuery",
+			fmt.Sprintf("keyspace %s exceeded its quota of %d concurrent queries", ksid, lim.quota.ConcurrentQueries),
+		)
+	}
+
+	lim.queries++
+
+	return func() {
+		l.mtx.Lock()
+		lim.queries--
+		l.mtx.Unlock()
+	}, nil
+}
@@ -0,0 +1,12 @@
+package limiter
+
+func statsQuotaExceeded(ks, quota string) {
+	go statsIncrement(
+		"mycenae.quota.exceeded",
+		map[string]string{"keyspace": ks, "quota": quota},
+	)
+}
+
+func statsIncrement(metric string, tags map[string]string) {
+	stats.Increment("limiter", metric, tags)
+}
@@ -12,9 +12,10 @@ import (
 
 func errBasic(f, s string, e error) gobol.Error {
 	if e != nil {
-		return tserr.New(
+		return tserr.NewCode(
 			e,
 			s,
+			tserr.InvalidQuery,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"package": "parse",
@@ -55,16 +56,32 @@ func errParseMap(s string) gobol.Error {
 	return errBasic("parseMap", s, errors.New(s))
 }
 
-func errRateCounter(e error) gobol.Error {
-	return errBasic("parseRate", "rate counter, the 1st parameter, needs to be a boolean", e)
+func errRateCounter(name string, e error) gobol.Error {
+	return errBasic("parseRate", fmt.Sprintf("%s counter, the 1st parameter, needs to be a boolean", name), e)
 }
 
-func errRateCounterMax(e error) gobol.Error {
-	return errBasic("parseRate", `rate counterMax, the 2nd parameter, needs to be an integer or the string 'null'`, e)
+func errRateCounterMax(name string, e error) gobol.Error {
+	return errBasic("parseRate", fmt.Sprintf(`%s counterMax, the 2nd parameter, needs to be an integer or the string 'null'`, name), e)
 }
 
-func errRateResetValue(e error) gobol.Error {
-	return errBasic("parseRate", "rate resetValue, the 3rd parameter, needs to be an integer", e)
+func errRateResetValue(name string, e error) gobol.Error {
+	return errBasic("parseRate", fmt.Sprintf("%s resetValue, the 3rd parameter, needs to be an integer", name), e)
+}
+
+func errRateDropResets(name string, e error) gobol.Error {
+	return errBasic("parseRate", fmt.Sprintf("%s dropResets, the 4th parameter, needs to be a boolean", name), e)
+}
+
+func errRateDuration(name, d string) gobol.Error {
+	return errBasic("parseRate", fmt.Sprintf("%s %s %q needs to be a duration like 1m or 1h", name, rateDuration(name), d), errors.New(d))
+}
+
+func errRankSize(name string, e error) gobol.Error {
+	return errBasic("parseRank", fmt.Sprintf("%s number of series, the 1st parameter, needs to be an integer", name), e)
+}
+
+func errWindowNumber(name, param string, e error) gobol.Error {
+	return errBasic("parseWindow", fmt.Sprintf("%s %s, needs to be a number", name, param), e)
 }
 
 func errUnkFunc(s string) gobol.Error {
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseExplicitTags(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[12:]))
+
+	if len(params) != 1 {
+		return "", errParams(
+			"parseExplicitTags",
+			"explicitTags needs 1 parameter: a function",
+			fmt.Errorf("explicitTags expects 1 parameter but found %d: %v", len(params), params),
+		)
+	}
+
+	if tsdb.ExplicitTags {
+		return "", errDoubleFunc("parseExplicitTags", "explicitTags")
+	}
+
+	tsdb.ExplicitTags = true
+
+	return params[0], nil
+}
+
+func writeExplicitTags(exp string, explicit bool) string {
+	if explicit {
+		return fmt.Sprintf("explicitTags(%s)", exp)
+	}
+	return exp
+}
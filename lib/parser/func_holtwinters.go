@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseHoltWinters(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[11:]))
+
+	if len(params) != 2 {
+		return "", errParams(
+			"parseHoltWinters",
+			"holtWinters needs 2 parameters: a season, as a number of points or a time interval, and a function",
+			fmt.Errorf("holtWinters expects 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	if tsdb.HoltWinters != nil {
+		return "", errDoubleFunc("parseHoltWinters", "holtWinters")
+	}
+
+	tsdb.HoltWinters = &structs.TSDBholtWinters{
+		Season: params[0],
+	}
+
+	return params[1], nil
+}
+
+func writeHoltWinters(exp string, hw *structs.TSDBholtWinters) string {
+	if hw != nil {
+		return fmt.Sprintf("holtWinters(%s,%s)", hw.Season, exp)
+	}
+	return exp
+}
@@ -3,7 +3,6 @@ package parser
 import (
 	"fmt"
 	"sort"
-	"strings"
 
 	"github.com/uol/gobol"
 
@@ -38,24 +37,7 @@ func parseQuery(exp string, tsdb *structs.TSDBquery) (e string, err gobol.Error)
 	for k, vs := range tags {
 		for _, v := range vs {
 
-			var ft, cv string
-
-			if strings.HasPrefix(v, "regexp(") && strings.HasSuffix(v, ")") {
-				ft = "regexp"
-				cv = v[7 : len(v)-1]
-			} else if strings.HasPrefix(v, "wildcard(") && strings.HasSuffix(v, ")") {
-				ft = "wildcard"
-				cv = v[9 : len(v)-1]
-			} else if strings.HasPrefix(v, "or(") && strings.HasSuffix(v, ")") {
-				ft = "literal_or"
-				cv = v[3 : len(v)-1]
-			} else if strings.HasPrefix(v, "notor(") && strings.HasSuffix(v, ")") {
-				ft = "not_literal_or"
-				cv = v[6 : len(v)-1]
-			} else {
-				ft = "wildcard"
-				cv = v
-			}
+			ft, cv := parseFilterValue(v)
 
 			filter := structs.TSDBfilter{
 				Ftype:   ft,
@@ -110,6 +92,10 @@ func writeQuery(metric, relative string, filters []structs.TSDBfilter) string {
 						joinFilters[filter.Tagk] = []string{
 							fmt.Sprintf("notor(%s)", filter.Filter),
 						}
+					default:
+						joinFilters[filter.Tagk] = []string{
+							writeFilterValue(filter),
+						}
 					}
 					orderedTags = append(orderedTags, filter.Tagk)
 				} else {
@@ -137,6 +123,11 @@ func writeQuery(metric, relative string, filters []structs.TSDBfilter) string {
 							joinFilters[filter.Tagk],
 							fmt.Sprintf("notor(%s)", filter.Ftype, filter.Filter),
 						)
+					default:
+						joinFilters[filter.Tagk] = append(
+							joinFilters[filter.Tagk],
+							writeFilterValue(filter),
+						)
 					}
 				}
 			}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseRank(name, exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[len(name):]))
+
+	if len(params) != 3 {
+		return "", errParams(
+			"parseRank",
+			fmt.Sprintf("%s needs 3 parameters: number of series, avg, max, min or last and a function", name),
+			fmt.Errorf("%s expects 3 parameters but found %d: %v", name, len(params), params),
+		)
+	}
+
+	if tsdb.Rank != nil {
+		return "", errDoubleFunc("parseRank", "topk or bottomk")
+	}
+
+	size, err := strconv.Atoi(params[0])
+	if err != nil {
+		return "", errRankSize(name, err)
+	}
+
+	tsdb.Rank = &structs.TSDBrank{
+		Type:     name,
+		Size:     size,
+		Function: params[1],
+	}
+
+	return params[2], nil
+}
+
+func writeRank(exp string, rank *structs.TSDBrank) string {
+	if rank != nil {
+		return fmt.Sprintf("%s(%d,%s,%s)", rank.Type, rank.Size, rank.Function, exp)
+	}
+	return exp
+}
@@ -3,27 +3,40 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/uol/gobol"
 
 	"github.com/uol/mycenae/lib/structs"
 )
 
-func parseRate(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+// parseRate parses rate, irate and increase. They take counter, counterMax,
+// resetValue, optionally dropResets and a duration, and a function. The
+// duration is the unit of rate and irate and the window of increase
+func parseRate(name, exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
 
-	params := parseParams(string(exp[4:]))
+	params := parseParams(string(exp[len(name):]))
 
-	if len(params) != 4 {
+	if len(params) < 4 || len(params) > 6 {
 		return "", errParams(
 			"parseRate",
-			"rate needs 4 parameters: counter, counterMax, resetValue and a function",
-			fmt.Errorf("rate expects 4 parameters but found %d: %v", len(params), params),
+			fmt.Sprintf(
+				"%s needs 4 to 6 parameters: counter, counterMax, resetValue, optionally dropResets and a %s, and a function",
+				name,
+				rateDuration(name),
+			),
+			fmt.Errorf("%s expects 4 to 6 parameters but found %d: %v", name, len(params), params),
 		)
 	}
 
+	gerr := checkRateOrder(tsdb)
+	if gerr != nil {
+		return "", gerr
+	}
+
 	b, err := strconv.ParseBool(params[0])
 	if err != nil {
-		return "", errRateCounter(err)
+		return "", errRateCounter(name, err)
 	}
 
 	tsdb.RateOptions.Counter = b
@@ -31,38 +44,132 @@ func parseRate(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
 	if params[1] != "null" {
 		counterMax, err := strconv.ParseInt(params[1], 10, 64)
 		if err != nil {
-			return "", errRateCounterMax(err)
+			return "", errRateCounterMax(name, err)
 		}
 		tsdb.RateOptions.CounterMax = &counterMax
 	}
 
 	tsdb.RateOptions.ResetValue, err = strconv.ParseInt(params[2], 10, 64)
 	if err != nil {
-		return "", errRateResetValue(err)
+		return "", errRateResetValue(name, err)
+	}
+
+	optional := params[3 : len(params)-1]
+
+	if len(optional) > 0 {
+		if dr, err := strconv.ParseBool(optional[0]); err == nil {
+			tsdb.RateOptions.DropResets = dr
+			optional = optional[1:]
+		} else if len(optional) == 2 {
+			return "", errRateDropResets(name, err)
+		}
+	}
+
+	if len(optional) > 0 {
+		d := optional[0]
+		if len(d) < 2 {
+			return "", errRateDuration(name, d)
+		}
+		if _, gerr := GetRelativeStart(time.Now(), d); gerr != nil {
+			return "", errRateDuration(name, d)
+		}
+		if name == "increase" {
+			tsdb.RateOptions.Window = d
+		} else {
+			tsdb.RateOptions.Unit = d
+		}
+	}
+
+	if name != "rate" {
+		tsdb.RateOptions.Function = name
+	}
+
+	tsdb.Rate = true
+
+	tsdb.Order = append([]string{"rate"}, tsdb.Order...)
+
+	return params[len(params)-1], nil
+}
+
+// parseDelta parses delta and nonNegativeDelta, they take only a function
+func parseDelta(name, exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[len(name):]))
+
+	if len(params) != 1 {
+		return "", errParams(
+			"parseDelta",
+			fmt.Sprintf("%s needs 1 parameter: a function", name),
+			fmt.Errorf("%s expects 1 parameter but found %d: %v", name, len(params), params),
+		)
+	}
+
+	gerr := checkRateOrder(tsdb)
+	if gerr != nil {
+		return "", gerr
 	}
 
+	tsdb.RateOptions.Function = name
+
 	tsdb.Rate = true
 
+	tsdb.Order = append([]string{"rate"}, tsdb.Order...)
+
+	return params[0], nil
+}
+
+// checkRateOrder fails when the expression already has a function of the
+// rate family, they share the rate step of the query
+func checkRateOrder(tsdb *structs.TSDBquery) gobol.Error {
+
 	for _, oper := range tsdb.Order {
 		if oper == "rate" {
-			return "", errDoubleFunc("parseRate", "rate")
+			return errDoubleFunc("parseRate", "rate, irate, increase, delta or nonNegativeDelta")
 		}
 	}
 
-	tsdb.Order = append([]string{"rate"}, tsdb.Order...)
+	return nil
+}
 
-	return params[3], nil
+// rateDuration names the optional duration of a rate function
+func rateDuration(name string) string {
+	if name == "increase" {
+		return "window"
+	}
+	return "unit"
 }
 
 func writeRate(exp string, rate bool, rateOptions structs.TSDBrateOptions) string {
 	if rate {
+		name := "rate"
+
+		if rateOptions.Function != "" {
+			name = rateOptions.Function
+		}
+
+		if name == "delta" || name == "nonNegativeDelta" {
+			return fmt.Sprintf("%s(%s)", name, exp)
+		}
+
 		cm := "null"
 
 		if rateOptions.CounterMax != nil {
 			cm = fmt.Sprintf("%d", *rateOptions.CounterMax)
 		}
 
-		exp = fmt.Sprintf("rate(%t,%s,%d,%s)", rateOptions.Counter, cm, rateOptions.ResetValue, exp)
+		params := fmt.Sprintf("%t,%s,%d", rateOptions.Counter, cm, rateOptions.ResetValue)
+
+		if rateOptions.DropResets {
+			params += ",true"
+		}
+
+		if name == "increase" && rateOptions.Window != "" {
+			params += "," + rateOptions.Window
+		} else if name != "increase" && rateOptions.Unit != "" {
+			params += "," + rateOptions.Unit
+		}
+
+		exp = fmt.Sprintf("%s(%s,%s)", name, params, exp)
 	}
 	return exp
 }
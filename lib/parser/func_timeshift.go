@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseTimeShift(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[9:]))
+
+	if len(params) != 2 {
+		return "", errParams(
+			"parseTimeShift",
+			"timeShift needs 2 parameters: a time interval and a function",
+			fmt.Errorf("timeShift expects 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	if tsdb.TimeShift != "" {
+		return "", errDoubleFunc("parseTimeShift", "timeShift")
+	}
+
+	tsdb.TimeShift = params[0]
+
+	return params[1], nil
+}
+
+func writeTimeShift(exp, timeShift string) string {
+	if timeShift != "" {
+		return fmt.Sprintf("timeShift(%s,%s)", timeShift, exp)
+	}
+	return exp
+}
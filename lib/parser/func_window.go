@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseWindow(name, exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[len(name):]))
+
+	w := &structs.TSDBwindow{}
+
+	var err error
+
+	switch name {
+	case "movingAverage", "rollingMax":
+		if len(params) != 2 {
+			return "", errParams(
+				"parseWindow",
+				fmt.Sprintf("%s needs 2 parameters: a window and a function", name),
+				fmt.Errorf("%s expects 2 parameters but found %d: %v", name, len(params), params),
+			)
+		}
+		w.Window = params[0]
+	case "ewma":
+		if len(params) != 2 {
+			return "", errParams(
+				"parseWindow",
+				"ewma needs 2 parameters: alpha and a function",
+				fmt.Errorf("ewma expects 2 parameters but found %d: %v", len(params), params),
+			)
+		}
+		w.Alpha, err = strconv.ParseFloat(params[0], 64)
+		if err != nil {
+			return "", errWindowNumber(name, "alpha, the 1st parameter", err)
+		}
+	case "rollingPercentile":
+		if len(params) != 3 {
+			return "", errParams(
+				"parseWindow",
+				"rollingPercentile needs 3 parameters: percentile, a window and a function",
+				fmt.Errorf("rollingPercentile expects 3 parameters but found %d: %v", len(params), params),
+			)
+		}
+		w.Percentile, err = strconv.ParseFloat(params[0], 64)
+		if err != nil {
+			return "", errWindowNumber(name, "percentile, the 1st parameter", err)
+		}
+		w.Window = params[1]
+	}
+
+	for _, oper := range tsdb.Order {
+		if oper == name {
+			return "", errDoubleFunc("parseWindow", name)
+		}
+	}
+
+	switch name {
+	case "movingAverage":
+		tsdb.MovingAverage = w
+	case "ewma":
+		tsdb.Ewma = w
+	case "rollingMax":
+		tsdb.RollingMax = w
+	case "rollingPercentile":
+		tsdb.RollingPercentile = w
+	}
+
+	tsdb.Order = append([]string{name}, tsdb.Order...)
+
+	return params[len(params)-1], nil
+}
+
+func writeWindow(exp, name string, w *structs.TSDBwindow) string {
+
+	if w == nil {
+		return exp
+	}
+
+	switch name {
+	case "ewma":
+		return fmt.Sprintf("ewma(%s,%s)", strconv.FormatFloat(w.Alpha, 'f', -1, 64), exp)
+	case "rollingPercentile":
+		return fmt.Sprintf(
+			"rollingPercentile(%s,%s,%s)",
+			strconv.FormatFloat(w.Percentile, 'f', -1, 64),
+			w.Window,
+			exp,
+		)
+	}
+
+	return fmt.Sprintf("%s(%s,%s)", name, w.Window, exp)
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+func parseZScore(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[6:]))
+
+	if len(params) != 1 {
+		return "", errParams(
+			"parseZScore",
+			"zscore needs 1 parameter: a function",
+			fmt.Errorf("zscore expects 1 parameter but found %d: %v", len(params), params),
+		)
+	}
+
+	for _, oper := range tsdb.Order {
+		if oper == "zscore" {
+			return "", errDoubleFunc("parseZScore", "zscore")
+		}
+	}
+
+	tsdb.ZScore = true
+
+	tsdb.Order = append([]string{"zscore"}, tsdb.Order...)
+
+	return params[0], nil
+}
+
+func parseOutliers(exp string, tsdb *structs.TSDBquery) (string, gobol.Error) {
+
+	params := parseParams(string(exp[8:]))
+
+	if len(params) != 2 {
+		return "", errParams(
+			"parseOutliers",
+			"outliers needs 2 parameters: a z-score threshold and a function",
+			fmt.Errorf("outliers expects 2 parameters but found %d: %v", len(params), params),
+		)
+	}
+
+	threshold, err := strconv.ParseFloat(params[0], 64)
+	if err != nil {
+		return "", errBasic("parseOutliers", "outliers threshold, the 1st parameter, needs to be a number", err)
+	}
+
+	for _, oper := range tsdb.Order {
+		if oper == "outliers" {
+			return "", errDoubleFunc("parseOutliers", "outliers")
+		}
+	}
+
+	tsdb.Outliers = threshold
+
+	tsdb.Order = append([]string{"outliers"}, tsdb.Order...)
+
+	return params[1], nil
+}
+
+func writeZScore(exp string, zscore bool) string {
+	if zscore {
+		return fmt.Sprintf("zscore(%s)", exp)
+	}
+	return exp
+}
+
+func writeOutliers(exp string, threshold float64) string {
+	if threshold != 0 {
+		return fmt.Sprintf("outliers(%s,%s)", strconv.FormatFloat(threshold, 'f', -1, 64), exp)
+	}
+	return exp
+}
@@ -1,10 +1,14 @@
 package parser
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
 )
 
 // GetRelativeStart returns a start time based on an end time and a duration string
@@ -36,6 +40,45 @@ func GetRelativeStart(end time.Time, s string) (time.Time, gobol.Error) {
 	return time.Time{}, errBadUnit()
 }
 
+// parseFilterValue returns the filter type and value of a map value like
+// or(a|b), notor(a|b), ior(a|b), notior(a|b), wildcard(a*), iwildcard(a*),
+// regexp(a.*) or notkey()
+func parseFilterValue(v string) (string, string) {
+
+	for _, f := range []struct{ prefix, ftype string }{
+		{"regexp(", "regexp"},
+		{"wildcard(", "wildcard"},
+		{"iwildcard(", "iwildcard"},
+		{"or(", "literal_or"},
+		{"ior(", "iliteral_or"},
+		{"notor(", "not_literal_or"},
+		{"notior(", "not_iliteral_or"},
+		{"notkey(", "not_key"},
+	} {
+		if strings.HasPrefix(v, f.prefix) && strings.HasSuffix(v, ")") {
+			return f.ftype, v[len(f.prefix) : len(v)-1]
+		}
+	}
+
+	return "wildcard", v
+}
+
+func writeFilterValue(filter structs.TSDBfilter) string {
+
+	switch filter.Ftype {
+	case "iwildcard":
+		return fmt.Sprintf("iwildcard(%s)", filter.Filter)
+	case "iliteral_or":
+		return fmt.Sprintf("ior(%s)", filter.Filter)
+	case "not_iliteral_or":
+		return fmt.Sprintf("notior(%s)", filter.Filter)
+	case "not_key":
+		return "notkey()"
+	}
+
+	return filter.Filter
+}
+
 func parseParams(exp string) []string {
 
 	var param []byte
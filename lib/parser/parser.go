@@ -48,10 +48,26 @@ func parseExpression(exp string, tsdb *structs.TSDBquery) (relative string, err
 		exp, err = parseDownsample(exp, tsdb)
 	case "groupBy":
 		exp, err = parseGroup(exp, tsdb)
-	case "rate":
-		exp, err = parseRate(exp, tsdb)
+	case "rate", "irate", "increase":
+		exp, err = parseRate(string(name), exp, tsdb)
+	case "delta", "nonNegativeDelta":
+		exp, err = parseDelta(string(name), exp, tsdb)
 	case "filter":
 		exp, err = parseFilter(exp, tsdb)
+	case "movingAverage", "ewma", "rollingMax", "rollingPercentile":
+		exp, err = parseWindow(string(name), exp, tsdb)
+	case "zscore":
+		exp, err = parseZScore(exp, tsdb)
+	case "outliers":
+		exp, err = parseOutliers(exp, tsdb)
+	case "holtWinters":
+		exp, err = parseHoltWinters(exp, tsdb)
+	case "explicitTags":
+		exp, err = parseExplicitTags(exp, tsdb)
+	case "timeShift":
+		exp, err = parseTimeShift(exp, tsdb)
+	case "topk", "bottomk":
+		exp, err = parseRank(string(name), exp, tsdb)
 	default:
 		return "", errUnkFunc(fmt.Sprintf("unkown function %s", string(name)))
 	}
@@ -84,12 +100,26 @@ func CompileExpression(tsQueries []structs.TSDBqueryPayload) (exps []string) {
 					exp = writeRate(exp, query.Rate, query.RateOptions)
 				case "filterValue":
 					exp = writeFilter(exp, query.FilterValue)
+				case "movingAverage", "ewma", "rollingMax", "rollingPercentile":
+					exp = writeWindow(exp, operation, query.Windows()[operation])
+				case "zscore":
+					exp = writeZScore(exp, query.ZScore)
+				case "outliers":
+					exp = writeOutliers(exp, query.Outliers)
 				}
 
 			}
 
 			exp = writeGroup(exp, query.Filters)
 
+			exp = writeExplicitTags(exp, query.ExplicitTags)
+
+			exp = writeTimeShift(exp, query.TimeShift)
+
+			exp = writeHoltWinters(exp, query.HoltWinters)
+
+			exp = writeRank(exp, query.Rank)
+
 			exps = append(exps, exp)
 
 		}
@@ -0,0 +1,246 @@
+package plot
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uol/gobol"
+)
+
+// bucketCache is a read-through LRU cache of timeseries buckets. Each entry
+// keeps the points of a contiguous time range that is older than the settle
+// interval, newer points are always read from cassandra.
+type bucketCache struct {
+	mtx       sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	points    int
+	maxPoints int
+	settle    int64
+	ttl       time.Duration
+}
+
+type cacheEntry struct {
+	key     string
+	data    Pnts
+	from    int64
+	until   int64
+	created time.Time
+}
+
+// cacheMaxTTL bounds how long a bucket is served from memory. Writes only
+// invalidate the cache of the node that received them, the other nodes serve
+// their cached buckets until they expire
+const cacheMaxTTL = 15 * time.Minute
+
+func newBucketCache(maxPoints int, settle, ttl time.Duration) *bucketCache {
+
+	if maxPoints < 1 {
+		return nil
+	}
+
+	return &bucketCache{
+		entries:   map[string]*list.Element{},
+		lru:       list.New(),
+		maxPoints: maxPoints,
+		settle:    int64(settle / time.Millisecond),
+		ttl:       ttl,
+	}
+}
+
+func cacheKey(keyspace, key string) string {
+	return fmt.Sprintf("%s|%s", keyspace, key)
+}
+
+// get returns a copy of the cached points of key between start and end and
+// the last timestamp covered by the cache. ok is false when the cache does
+// not cover start
+func (bc *bucketCache) get(keyspace, key string, start, end int64) (points Pnts, until int64, ok bool) {
+
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+
+	el, found := bc.entries[cacheKey(keyspace, key)]
+	if !found {
+		return nil, 0, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+
+	if bc.ttl > 0 && time.Since(entry.created) > bc.ttl {
+		bc.remove(el)
+		return nil, 0, false
+	}
+
+	if entry.from > start || entry.until < start {
+		return nil, 0, false
+	}
+
+	bc.lru.MoveToFront(el)
+
+	i := sort.Search(len(entry.data), func(i int) bool { return entry.data[i].Date >= start })
+	j := sort.Search(len(entry.data), func(i int) bool { return entry.data[i].Date > end })
+
+	points = make(Pnts, j-i)
+	copy(points, entry.data[i:j])
+
+	return points, entry.until, true
+}
+
+// put stores the points of key between from and end, keeping only the ones
+// older than the settle interval. Points are appended to an entry that ends
+// where the new range starts
+func (bc *bucketCache) put(keyspace, key string, from, end int64, points Pnts) {
+
+	until := time.Now().UnixNano()/1e+6 - bc.settle
+
+	if end < until {
+		until = end
+	}
+
+	if until < from {
+		return
+	}
+
+	j := sort.Search(len(points), func(i int) bool { return points[i].Date > until })
+
+	settled := make(Pnts, j)
+	copy(settled, points[:j])
+
+	ck := cacheKey(keyspace, key)
+
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+
+	if el, found := bc.entries[ck]; found {
+
+		entry := el.Value.(*cacheEntry)
+
+		if entry.until >= from-1 && entry.from <= from {
+			k := sort.Search(len(entry.data), func(i int) bool { return entry.data[i].Date >= from })
+			bc.points -= len(entry.data) - k
+			entry.data = append(entry.data[:k], settled...)
+			bc.points += len(settled)
+			if until > entry.until {
+				entry.until = until
+			}
+			bc.lru.MoveToFront(el)
+			bc.evict()
+			return
+		}
+
+		bc.remove(el)
+	}
+
+	if len(settled) > bc.maxPoints {
+		return
+	}
+
+	bc.entries[ck] = bc.lru.PushFront(&cacheEntry{
+		key:     ck,
+		data:    settled,
+		from:    from,
+		until:   until,
+		created: time.Now(),
+	})
+
+	bc.points += len(settled)
+
+	bc.evict()
+}
+
+// invalidate drops the cached points of a bucket when a point older than the
+// settle interval is written to it
+func (bc *bucketCache) invalidate(keyspace, key string, timestamp int64) {
+
+	if timestamp > time.Now().UnixNano()/1e+6-bc.settle {
+		return
+	}
+
+	bc.mtx.Lock()
+	defer bc.mtx.Unlock()
+
+	if el, found := bc.entries[cacheKey(keyspace, key)]; found {
+		entry := el.Value.(*cacheEntry)
+		if timestamp <= entry.until {
+			bc.remove(el)
+			statsCacheInvalidation(keyspace)
+		}
+	}
+}
+
+func (bc *bucketCache) remove(el *list.Element) {
+	entry := bc.lru.Remove(el).(*cacheEntry)
+	delete(bc.entries, entry.key)
+	bc.points -= len(entry.data)
+}
+
+func (bc *bucketCache) evict() {
+	for bc.points > bc.maxPoints && bc.lru.Len() > 0 {
+		bc.remove(bc.lru.Back())
+	}
+}
+
+// InvalidateCache must be called for every point written, it drops cached
+// buckets that are older than the written point
+func (plot *Plot) InvalidateCache(keyspace, key string, timestamp int64) {
+	if plot.cache != nil {
+		plot.cache.invalidate(keyspace, key, timestamp)
+	}
+}
+
+// getTS reads the points of a bucket through the cache, only the part of
+// the range not covered by it is read from cassandra
+func (plot *Plot) getTS(ctx context.Context, keyspace, key string, start, end int64, tuuid, ms bool, qs *QueryStats) (Pnts, int, gobol.Error) {
+
+	if plot.cache == nil {
+		points, count, gerr := plot.persist.GetTS(ctx, keyspace, key, start, end, tuuid, ms)
+		qs.addRows(count)
+		return points, count, gerr
+	}
+
+	points, until, ok := plot.cache.get(keyspace, key, start, end)
+
+	from := start
+
+	if ok {
+		if end <= until {
+			statsCacheHit(keyspace)
+			return truncateMs(points, ms), len(points), nil
+		}
+		statsCachePartial(keyspace)
+		if until >= start {
+			from = until + 1
+		}
+	} else {
+		statsCacheMiss(keyspace)
+	}
+
+	tail, count, gerr := plot.persist.GetTS(ctx, keyspace, key, from, end, tuuid, true)
+	if gerr != nil {
+		return nil, 0, gerr
+	}
+
+	qs.addRows(count)
+
+	plot.cache.put(keyspace, key, from, end, tail)
+
+	points = append(points, tail...)
+
+	return truncateMs(points, ms), len(points), nil
+}
+
+func truncateMs(points Pnts, ms bool) Pnts {
+
+	if !ms {
+		for i := range points {
+			points[i].Date = (points[i].Date / 1000) * 1000
+		}
+	}
+
+	return points
+}
@@ -2,6 +2,8 @@ package plot
 
 import (
 	"math"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/uol/mycenae/lib/structs"
@@ -87,7 +89,77 @@ func basic(totalPoints int, serie []Pnt) (groupSerie []Pnt) {
 
 }
 
-func rate(options structs.TSDBrateOptions, serie Pnts) Pnts {
+// counterMax is where a counter that decreased wrapped, 2^64 without an
+// explicit counterMax. 32 bit counters need counterMax 4294967295
+func counterMax(options structs.TSDBrateOptions) float64 {
+
+	if options.CounterMax != nil {
+		return float64(*options.CounterMax)
+	}
+
+	return 1 << 64
+}
+
+// counterDelta returns the change between two values and if it is known. The
+// change of a counter that decreased isn't known with dropResets, otherwise
+// the counter wrapped at its max
+func counterDelta(options structs.TSDBrateOptions, prev, cur float64) (delta float64, wrapped, ok bool) {
+
+	if !options.Counter || cur >= prev {
+		return cur - prev, false, true
+	}
+
+	if options.DropResets {
+		return 0, false, false
+	}
+
+	return counterMax(options) - prev + cur, true, true
+}
+
+// pointRate is the rate between two points per unit, in milliseconds. A
+// wrapped counter rate above resetValue, per second, is taken as a reset and
+// is zero, like OpenTSDB does
+func pointRate(options structs.TSDBrateOptions, unit int64, prev, cur Pnt) (float64, bool) {
+
+	if cur.Date <= prev.Date {
+		return 0, false
+	}
+
+	delta, wrapped, ok := counterDelta(options, prev.Value, cur.Value)
+	if !ok {
+		return 0, false
+	}
+
+	perSecond := delta / (float64(cur.Date-prev.Date) / 1000)
+
+	if wrapped && options.ResetValue > 0 && perSecond > float64(options.ResetValue) {
+		return 0, true
+	}
+
+	return perSecond * float64(unit) / 1000, true
+}
+
+// rate applies the rate function of the options to the serie
+func rate(oper structs.RateOperation, serie Pnts) Pnts {
+
+	unit := oper.Unit
+	if unit <= 0 {
+		unit = 1000
+	}
+
+	switch oper.Options.Function {
+	case "irate":
+		return irate(oper.Options, unit, serie)
+	case "increase":
+		if oper.Window > 0 {
+			return windowIncrease(oper.Options, oper.Window, serie)
+		}
+		return increase(oper.Options, serie)
+	case "delta":
+		return delta(false, serie)
+	case "nonNegativeDelta":
+		return delta(true, serie)
+	}
 
 	if len(serie) == 1 {
 		return serie
@@ -106,15 +178,9 @@ func rate(options structs.TSDBrateOptions, serie Pnts) Pnts {
 			continue
 		}
 
-		var value float64
-
-		if options.Counter && serie[i].Value < serie[i-1].Value {
-			value = (float64(*options.CounterMax) + serie[i].Value - serie[i-1].Value) / float64((serie[i].Date/int64(1000))-(serie[i-1].Date/int64(1000)))
-			if options.ResetValue != 0 && float64(options.ResetValue) <= value {
-				value = 0
-			}
-		} else {
-			value = (serie[i].Value - serie[i-1].Value) / float64((serie[i].Date/int64(1000))-(serie[i-1].Date/int64(1000)))
+		value, ok := pointRate(oper.Options, unit, serie[i-1], serie[i])
+		if !ok {
+			continue
 		}
 
 		p := Pnt{
@@ -129,6 +195,164 @@ func rate(options structs.TSDBrateOptions, serie Pnts) Pnts {
 	return rateSerie
 }
 
+// irate is the instant rate of the serie, the rate between its last two
+// points at the date of the last one
+func irate(options structs.TSDBrateOptions, unit int64, serie Pnts) Pnts {
+
+	last, prev := -1, -1
+
+	for i := len(serie) - 1; i >= 0 && prev < 0; i-- {
+		if serie[i].Empty {
+			continue
+		}
+		if last < 0 {
+			last = i
+		} else {
+			prev = i
+		}
+	}
+
+	if prev < 0 {
+		return Pnts{}
+	}
+
+	value, ok := pointRate(options, unit, serie[prev], serie[last])
+	if !ok {
+		return Pnts{}
+	}
+
+	return Pnts{{Date: serie[last].Date, Value: value}}
+}
+
+// increaseDelta is how much a counter increased between two points. Resets,
+// dropped or above resetValue, don't count
+func increaseDelta(options structs.TSDBrateOptions, prev, cur Pnt) (float64, bool) {
+
+	if cur.Date <= prev.Date {
+		return 0, false
+	}
+
+	delta, wrapped, ok := counterDelta(options, prev.Value, cur.Value)
+	if !ok {
+		return 0, false
+	}
+
+	perSecond := delta / (float64(cur.Date-prev.Date) / 1000)
+
+	if wrapped && options.ResetValue > 0 && perSecond > float64(options.ResetValue) {
+		return 0, false
+	}
+
+	return delta, true
+}
+
+// increase is how much a counter increased along the serie, at the date of
+// its last point
+func increase(options structs.TSDBrateOptions, serie Pnts) Pnts {
+
+	var total float64
+
+	prev := -1
+	points := 0
+
+	for i := range serie {
+
+		if serie[i].Empty {
+			continue
+		}
+
+		points++
+
+		if prev >= 0 {
+			if d, ok := increaseDelta(options, serie[prev], serie[i]); ok {
+				total += d
+			}
+		}
+
+		prev = i
+	}
+
+	if points < 2 {
+		return Pnts{}
+	}
+
+	return Pnts{{Date: serie[prev].Date, Value: total}}
+}
+
+// windowIncrease is, at every point, how much a counter increased in the
+// window, in milliseconds, ending at the point. Empty points are kept empty
+func windowIncrease(options structs.TSDBrateOptions, window int64, serie Pnts) Pnts {
+
+	type step struct {
+		date  int64
+		delta float64
+	}
+
+	increaseSerie := Pnts{}
+
+	steps := []step{}
+
+	prev := -1
+
+	for i := range serie {
+
+		if serie[i].Empty {
+			increaseSerie = append(increaseSerie, Pnt{Date: serie[i].Date, Empty: true})
+			continue
+		}
+
+		if prev < 0 {
+			prev = i
+			continue
+		}
+
+		if d, ok := increaseDelta(options, serie[prev], serie[i]); ok {
+			steps = append(steps, step{date: serie[i].Date, delta: d})
+		}
+
+		prev = i
+
+		for len(steps) > 0 && steps[0].date <= serie[i].Date-window {
+			steps = steps[1:]
+		}
+
+		var sum float64
+
+		for _, st := range steps {
+			sum += st.delta
+		}
+
+		increaseSerie = append(increaseSerie, Pnt{Date: serie[i].Date, Value: sum})
+	}
+
+	return increaseSerie
+}
+
+// delta is the difference between every point and the previous one, with
+// nonNegative decreases are dropped
+func delta(nonNegative bool, serie Pnts) Pnts {
+
+	deltaSerie := Pnts{}
+
+	for i := 1; i < len(serie); i++ {
+
+		if serie[i].Empty || serie[i-1].Empty {
+			deltaSerie = append(deltaSerie, Pnt{Date: serie[i].Date, Empty: true})
+			continue
+		}
+
+		d := serie[i].Value - serie[i-1].Value
+
+		if nonNegative && d < 0 {
+			continue
+		}
+
+		deltaSerie = append(deltaSerie, Pnt{Date: serie[i].Date, Value: d})
+	}
+
+	return deltaSerie
+}
+
 func downsample(options structs.DSoptions, keepEmpties bool, start, end int64, serie Pnts) Pnts {
 
 	startDate := time.Unix(0, start*1e+6)
@@ -526,3 +750,381 @@ func msToTime(ms int64) time.Time {
 func timeToMs(date time.Time) (ms int64) {
 	return date.UnixNano() / int64(time.Millisecond)
 }
+
+func rank(options structs.TSDBrank, series []tsdbSerie) []tsdbSerie {
+
+	type ranked struct {
+		serie tsdbSerie
+		value float64
+		empty bool
+	}
+
+	rs := make([]ranked, len(series))
+
+	for i, serie := range series {
+
+		r := ranked{serie: serie, empty: true}
+
+		var count float64
+
+		for _, point := range serie.Data {
+
+			if point.Empty {
+				continue
+			}
+
+			switch options.Function {
+			case "avg":
+				r.value += point.Value
+			case "max":
+				if r.empty || point.Value > r.value {
+					r.value = point.Value
+				}
+			case "min":
+				if r.empty || point.Value < r.value {
+					r.value = point.Value
+				}
+			case "last":
+				r.value = point.Value
+			}
+
+			r.empty = false
+			count++
+		}
+
+		if options.Function == "avg" && count > 0 {
+			r.value = r.value / count
+		}
+
+		rs[i] = r
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].empty != rs[j].empty {
+			return !rs[i].empty
+		}
+		if options.Type == "bottomk" {
+			return rs[i].value < rs[j].value
+		}
+		return rs[i].value > rs[j].value
+	})
+
+	if len(rs) > options.Size {
+		rs = rs[:options.Size]
+	}
+
+	rankedSeries := make([]tsdbSerie, len(rs))
+
+	for i, r := range rs {
+		rankedSeries[i] = r.serie
+	}
+
+	return rankedSeries
+}
+
+func windowOperation(w structs.TSDBwindow) structs.WindowOperation {
+
+	oper := structs.WindowOperation{
+		Alpha:      w.Alpha,
+		Percentile: w.Percentile,
+	}
+
+	if n, err := strconv.Atoi(w.Window); err == nil {
+		oper.Points = n
+		return oper
+	}
+
+	if len(w.Window) > 2 && w.Window[len(w.Window)-2:] == "ms" {
+		oper.Unit = "ms"
+		oper.Value, _ = strconv.Atoi(w.Window[:len(w.Window)-2])
+		return oper
+	}
+
+	if len(w.Window) > 1 {
+		oper.Value, _ = strconv.Atoi(w.Window[:len(w.Window)-1])
+		switch w.Window[len(w.Window)-1:] {
+		case "s":
+			oper.Unit = "sec"
+		case "m":
+			oper.Unit = "min"
+		case "h":
+			oper.Unit = "hour"
+		case "d":
+			oper.Unit = "day"
+		case "w":
+			oper.Unit = "week"
+		case "n":
+			oper.Unit = "month"
+		case "y":
+			oper.Unit = "year"
+		}
+	}
+
+	return oper
+}
+
+func windowStart(date int64, options structs.WindowOperation) int64 {
+
+	switch options.Unit {
+	case "month":
+		return timeToMs(msToTime(date).AddDate(0, -options.Value, 0))
+	case "year":
+		return timeToMs(msToTime(date).AddDate(-options.Value, 0, 0))
+	}
+
+	return date - (getEndInterval(date, options.Unit, options.Value) - date)
+}
+
+// window applies a moving window function, empty points are kept empty and
+// are ignored by the calculation of the following points
+func window(oper string, options structs.WindowOperation, serie Pnts) Pnts {
+
+	windowSerie := make(Pnts, 0, len(serie))
+
+	var ewma float64
+
+	ewmaInit := false
+
+	first := 0
+
+	values := []float64{}
+
+	for i, point := range serie {
+
+		if oper == "ewma" {
+
+			if point.Empty {
+				windowSerie = append(windowSerie, point)
+				continue
+			}
+
+			if ewmaInit {
+				ewma = options.Alpha*point.Value + (1-options.Alpha)*ewma
+			} else {
+				ewma = point.Value
+				ewmaInit = true
+			}
+
+			windowSerie = append(windowSerie, Pnt{Date: point.Date, Value: ewma})
+			continue
+		}
+
+		if options.Points > 0 {
+			for i-first >= options.Points {
+				first++
+			}
+		} else {
+			start := windowStart(point.Date, options)
+			for first < i && serie[first].Date <= start {
+				first++
+			}
+		}
+
+		if point.Empty {
+			windowSerie = append(windowSerie, point)
+			continue
+		}
+
+		values = values[:0]
+
+		for _, p := range serie[first : i+1] {
+			if !p.Empty {
+				values = append(values, p.Value)
+			}
+		}
+
+		if len(values) == 0 {
+			windowSerie = append(windowSerie, Pnt{Date: point.Date, Empty: true})
+			continue
+		}
+
+		p := Pnt{Date: point.Date}
+
+		switch oper {
+		case "movingAverage":
+			for _, v := range values {
+				p.Value += v
+			}
+			p.Value = p.Value / float64(len(values))
+		case "rollingMax":
+			p.Value = values[0]
+			for _, v := range values {
+				if v > p.Value {
+					p.Value = v
+				}
+			}
+		case "rollingPercentile":
+			p.Value = percentile(options.Percentile, values)
+		}
+
+		windowSerie = append(windowSerie, p)
+	}
+
+	return windowSerie
+}
+
+func percentile(pct float64, values []float64) float64 {
+
+	sorted := make([]float64, len(values))
+
+	copy(sorted, values)
+
+	sort.Float64s(sorted)
+
+	rank := pct / 100 * float64(len(sorted)-1)
+
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
+
+func zscore(serie Pnts) Pnts {
+
+	var sum, sqSum, count float64
+
+	for _, point := range serie {
+		if !point.Empty {
+			sum += point.Value
+			sqSum += point.Value * point.Value
+			count++
+		}
+	}
+
+	if count == 0 {
+		return serie
+	}
+
+	mean := sum / count
+
+	stdDev := math.Sqrt(sqSum/count - mean*mean)
+
+	zSerie := make(Pnts, len(serie))
+
+	for i, point := range serie {
+
+		zSerie[i] = point
+
+		if point.Empty {
+			continue
+		}
+
+		if stdDev == 0 {
+			zSerie[i].Value = 0
+		} else {
+			zSerie[i].Value = (point.Value - mean) / stdDev
+		}
+	}
+
+	return zSerie
+}
+
+// outliers keeps only the points whose z-score is at least threshold
+func outliers(threshold float64, serie Pnts) Pnts {
+
+	zSerie := zscore(serie)
+
+	outlierSerie := Pnts{}
+
+	for i, point := range zSerie {
+		if !point.Empty && math.Abs(point.Value) >= threshold {
+			outlierSerie = append(outlierSerie, serie[i])
+		}
+	}
+
+	return outlierSerie
+}
+
+// holtWinters returns the additive Holt-Winters forecast of a serie and its
+// upper and lower confidence bands, keyed by band name
+func holtWinters(options structs.TSDBholtWinters, serie Pnts) map[string]Pnts {
+
+	alpha, beta, gamma, deviations := options.Alpha, options.Beta, options.Gamma, options.Deviations
+
+	if alpha == 0 {
+		alpha = 0.1
+	}
+	if beta == 0 {
+		beta = 0.0035
+	}
+	if gamma == 0 {
+		gamma = 0.1
+	}
+	if deviations == 0 {
+		deviations = 3
+	}
+
+	points := Pnts{}
+
+	for _, point := range serie {
+		if !point.Empty {
+			points = append(points, point)
+		}
+	}
+
+	bands := map[string]Pnts{
+		"forecast": {},
+		"upper":    {},
+		"lower":    {},
+	}
+
+	season, err := strconv.Atoi(options.Season)
+	if err != nil && len(points) > 1 {
+		w := windowOperation(structs.TSDBwindow{Window: options.Season})
+		length := points[0].Date - windowStart(points[0].Date, w)
+		step := (points[len(points)-1].Date - points[0].Date) / int64(len(points)-1)
+		if step > 0 {
+			season = int(length / step)
+		}
+	}
+
+	if season < 2 || len(points) < 2*season {
+		return bands
+	}
+
+	var level, nextLevel float64
+
+	for i := 0; i < season; i++ {
+		level += points[i].Value
+		nextLevel += points[i+season].Value
+	}
+
+	level = level / float64(season)
+	nextLevel = nextLevel / float64(season)
+
+	trend := (nextLevel - level) / float64(season)
+
+	seasonals := make([]float64, season)
+
+	for i := 0; i < season; i++ {
+		seasonals[i] = points[i].Value - level
+	}
+
+	var deviation float64
+
+	for i, point := range points {
+
+		s := i % season
+
+		forecast := level + trend + seasonals[s]
+
+		if i >= season {
+			deviation = gamma*math.Abs(point.Value-forecast) + (1-gamma)*deviation
+
+			bands["forecast"] = append(bands["forecast"], Pnt{Date: point.Date, Value: forecast})
+			bands["upper"] = append(bands["upper"], Pnt{Date: point.Date, Value: forecast + deviations*deviation})
+			bands["lower"] = append(bands["lower"], Pnt{Date: point.Date, Value: forecast - deviations*deviation})
+		}
+
+		newLevel := alpha*(point.Value-seasonals[s]) + (1-alpha)*(level+trend)
+		trend = beta*(newLevel-level) + (1-beta)*trend
+		seasonals[s] = gamma*(point.Value-newLevel) + (1-gamma)*seasonals[s]
+		level = newLevel
+	}
+
+	return bands
+}
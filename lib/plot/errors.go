@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -39,16 +40,33 @@ func errBasic(f, s string, code int, e error) gobol.Error {
 	return nil
 }
 
+func errCode(f, s string, code tserr.Code, httpCode int, e error) gobol.Error {
+	return tserr.NewCode(
+		e,
+		s,
+		code,
+		httpCode,
+		map[string]interface{}{
+			"package": "plot",
+			"func":    f,
+		},
+	)
+}
+
 func errValidationS(f, s string) gobol.Error {
-	return errBasic(f, s, http.StatusBadRequest, errors.New(s))
+	return errCode(f, s, tserr.InvalidQuery, http.StatusBadRequest, errors.New(s))
+}
+
+func errTooLarge(f, s string) gobol.Error {
+	return errCode(f, s, tserr.QueryTooLarge, http.StatusBadRequest, errors.New(s))
 }
 
 func errNotFound(f string) gobol.Error {
-	return errBasic(f, "", http.StatusNotFound, errors.New(""))
+	return errCode(f, "", tserr.UnknownKeyspace, http.StatusNotFound, errors.New(""))
 }
 
 func errValidation(f, m string, e error) gobol.Error {
-	return errBasic(f, m, http.StatusBadRequest, e)
+	return errCode(f, m, tserr.InvalidQuery, http.StatusBadRequest, e)
 }
 
 func errNoContent(f string) gobol.Error {
@@ -56,21 +74,32 @@ func errNoContent(f string) gobol.Error {
 }
 
 func errParamSize(f string, e error) gobol.Error {
-	return errBasic(f, `query param "size" should be an integer number greater than zero`, http.StatusBadRequest, e)
+	return errCode(f, `query param "size" should be an integer number greater than zero`, tserr.InvalidQuery, http.StatusBadRequest, e)
 }
 
 func errParamFrom(f string, e error) gobol.Error {
-	return errBasic(f, `query param "from" should be an integer number greater or equals zero`, http.StatusBadRequest, e)
+	return errCode(f, `query param "from" should be an integer number greater or equals zero`, tserr.InvalidQuery, http.StatusBadRequest, e)
 }
 
 func errPersist(f string, e error) gobol.Error {
-	return errBasic(f, e.Error(), http.StatusInternalServerError, e)
+	return errCode(f, e.Error(), tserr.StorageUnavailable, http.StatusInternalServerError, e)
 }
 
 func errValidationE(f string, e error) gobol.Error {
-	return errBasic(f, e.Error(), http.StatusBadRequest, e)
+	return errCode(f, e.Error(), tserr.InvalidQuery, http.StatusBadRequest, e)
+}
+
+func errUnmarshal(f string, e error) gobol.Error {
+	return errCode(f, "Wrong JSON format", tserr.InvalidJSON, http.StatusBadRequest, e)
+}
+
+func errCanceled(f string, e error) gobol.Error {
+	if e == context.DeadlineExceeded {
+		return errBasic(f, "query exceeded the maximum allowed duration", http.StatusGatewayTimeout, e)
+	}
+	return errBasic(f, "query canceled", http.StatusRequestTimeout, e)
 }
 
 func errEmptyExpression(f string) gobol.Error {
-	return errBasic(f, "no expression found", http.StatusBadRequest, errors.New("no expression found"))
+	return errCode(f, "no expression found", tserr.InvalidQuery, http.StatusBadRequest, errors.New("no expression found"))
 }
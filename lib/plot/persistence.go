@@ -50,6 +50,22 @@ func (persist *persistence) ListESMetrics(
 	return nil
 }
 
+func (persist *persistence) ListESCardinality(
+	esIndex,
+	esType string,
+	esQuery interface{},
+	response *EsResponseCardinality,
+) gobol.Error {
+	start := time.Now()
+	_, err := persist.esTs.Query(esIndex, esType, esQuery, response)
+	if err != nil {
+		statsIndexError(esIndex, esType, "post")
+		return errPersist("ListESCardinality", err)
+	}
+	statsIndex(esIndex, esType, "post", time.Since(start))
+	return nil
+}
+
 func (persist *persistence) ListESTagKey(
 	esIndex,
 	esType string,
@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -9,16 +10,16 @@ import (
 	"github.com/uol/gobol"
 )
 
-func (persist *persistence) GetTS(keyspace, key string, start, end int64, tuuid, ms bool) (Pnts, int, gobol.Error) {
+func (persist *persistence) GetTS(ctx context.Context, keyspace, key string, start, end int64, tuuid, ms bool) (Pnts, int, gobol.Error) {
 
 	if tuuid {
-		return persist.getTSuuid(keyspace, key, start, end, ms)
+		return persist.getTSuuid(ctx, keyspace, key, start, end, ms)
 	}
 
-	return persist.getTStamp(keyspace, key, start, end, ms)
+	return persist.getTStamp(ctx, keyspace, key, start, end, ms)
 }
 
-func (persist *persistence) getTSuuid(keyspace, key string, start, end int64, ms bool) ([]Pnt, int, gobol.Error) {
+func (persist *persistence) getTSuuid(ctx context.Context, keyspace, key string, start, end int64, ms bool) ([]Pnt, int, gobol.Error) {
 	track := time.Now()
 	start--
 	end++
@@ -36,7 +37,7 @@ func (persist *persistence) getTSuuid(keyspace, key string, start, end int64, ms
 			key,
 			start,
 			end,
-		).Consistency(cons).RoutingKey([]byte(key)).Iter()
+		).Consistency(cons).RoutingKey([]byte(key)).WithContext(ctx).Iter()
 
 		points := []Pnt{}
 		var count int
@@ -55,6 +56,10 @@ func (persist *persistence) getTSuuid(keyspace, key string, start, end int64, ms
 
 		if err = iter.Close(); err != nil {
 
+			if ctx.Err() != nil {
+				return []Pnt{}, 0, errCanceled("getTSuuid", ctx.Err())
+			}
+
 			gblog.WithFields(logrus.Fields{
 				"package": "plot/persistence",
 				"func":    "getTSuuid",
@@ -74,7 +79,7 @@ func (persist *persistence) getTSuuid(keyspace, key string, start, end int64, ms
 	return []Pnt{}, 0, errPersist("getTSuuid", err)
 }
 
-func (persist *persistence) getTStamp(keyspace, key string, start, end int64, ms bool) ([]Pnt, int, gobol.Error) {
+func (persist *persistence) getTStamp(ctx context.Context, keyspace, key string, start, end int64, ms bool) ([]Pnt, int, gobol.Error) {
 	track := time.Now()
 	start--
 	end++
@@ -92,7 +97,7 @@ func (persist *persistence) getTStamp(keyspace, key string, start, end int64, ms
 			key,
 			start,
 			end,
-		).Consistency(cons).RoutingKey([]byte(key)).Iter()
+		).Consistency(cons).RoutingKey([]byte(key)).WithContext(ctx).Iter()
 
 		points := []Pnt{}
 		var count int
@@ -111,6 +116,10 @@ func (persist *persistence) getTStamp(keyspace, key string, start, end int64, ms
 
 		if err = iter.Close(); err != nil {
 
+			if ctx.Err() != nil {
+				return []Pnt{}, 0, errCanceled("getTStamp", ctx.Err())
+			}
+
 			gblog.WithFields(logrus.Fields{
 				"package": "plot/persistence",
 				"func":    "getTStamp",
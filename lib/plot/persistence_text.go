@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"time"
@@ -11,6 +12,7 @@ import (
 )
 
 func (persist *persistence) GetTST(
+	ctx context.Context,
 	keyspace,
 	key string,
 	start,
@@ -20,13 +22,14 @@ func (persist *persistence) GetTST(
 ) (TextPnts, int, gobol.Error) {
 
 	if tuuid {
-		return persist.getTSTuuid(keyspace, key, start, end, search)
+		return persist.getTSTuuid(ctx, keyspace, key, start, end, search)
 	}
 
-	return persist.getTSTstamp(keyspace, key, start, end, search)
+	return persist.getTSTstamp(ctx, keyspace, key, start, end, search)
 }
 
 func (persist *persistence) getTSTuuid(
+	ctx context.Context,
 	keyspace,
 	key string,
 	start,
@@ -50,7 +53,7 @@ func (persist *persistence) getTSTuuid(
 			key,
 			start,
 			end,
-		).Consistency(cons).RoutingKey([]byte(key)).Iter()
+		).Consistency(cons).RoutingKey([]byte(key)).WithContext(ctx).Iter()
 
 		points := []TextPnt{}
 		var count int
@@ -75,6 +78,10 @@ func (persist *persistence) getTSTuuid(
 
 		if err = iter.Close(); err != nil {
 
+			if ctx.Err() != nil {
+				return []TextPnt{}, 0, errCanceled("getTSTuuid", ctx.Err())
+			}
+
 			gblog.WithFields(logrus.Fields{
 				"package": "plot/persistence",
 				"func":    "getTSTuuid",
@@ -95,6 +102,7 @@ func (persist *persistence) getTSTuuid(
 }
 
 func (persist *persistence) getTSTstamp(
+	ctx context.Context,
 	keyspace,
 	key string,
 	start,
@@ -118,7 +126,7 @@ func (persist *persistence) getTSTstamp(
 			key,
 			start,
 			end,
-		).Consistency(cons).RoutingKey([]byte(key)).Iter()
+		).Consistency(cons).RoutingKey([]byte(key)).WithContext(ctx).Iter()
 
 		points := []TextPnt{}
 		var count int
@@ -142,6 +150,10 @@ func (persist *persistence) getTSTstamp(
 
 		if err = iter.Close(); err != nil {
 
+			if ctx.Err() != nil {
+				return []TextPnt{}, 0, errCanceled("getTSTstamp", ctx.Err())
+			}
+
 			gblog.WithFields(logrus.Fields{
 				"package": "plot/persistence",
 				"func":    "getTSTstamp",
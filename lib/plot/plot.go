@@ -1,12 +1,19 @@
 package plot
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rubber"
 
 	"github.com/uol/mycenae/lib/bcache"
+	"github.com/uol/mycenae/lib/limiter"
+	"github.com/uol/mycenae/lib/structs"
 	"github.com/uol/mycenae/lib/tsstats"
 )
 
@@ -21,11 +28,16 @@ func New(
 	cass *gocql.Session,
 	es *rubber.Elastic,
 	bc *bcache.Bcache,
+	lim *limiter.Limiter,
 	esIndex string,
 	maxTimeseries int,
 	maxConcurrentTimeseries int,
 	maxConcurrentReads int,
 	logQueryTSthreshold int,
+	slowQueryThreshold string,
+	maxQueryDuration string,
+	metaLastSeenInterval string,
+	queryCache structs.SettingsQueryCache,
 	consist []gocql.Consistency,
 ) (*Plot, gobol.Error) {
 
@@ -48,17 +60,98 @@ func New(
 		return nil, errInit("maxConcurrentTimeseries cannot be bigger than maxConcurrentReads")
 	}
 
+	var slow time.Duration
+
+	if slowQueryThreshold != "" {
+		d, err := time.ParseDuration(slowQueryThreshold)
+		if err != nil {
+			return nil, errInit("SlowQueryThreshold needs to be a valid duration")
+		}
+		slow = d
+	}
+
+	var maxQuery time.Duration
+
+	if maxQueryDuration != "" {
+		d, err := time.ParseDuration(maxQueryDuration)
+		if err != nil {
+			return nil, errInit("MaxQueryDuration needs to be a valid duration")
+		}
+		maxQuery = d
+	}
+
+	var lsi time.Duration
+
+	if metaLastSeenInterval != "" {
+		d, err := time.ParseDuration(metaLastSeenInterval)
+		if err != nil {
+			return nil, errInit("MetaLastSeenInterval needs to be a valid duration")
+		}
+		lsi = d
+	}
+
+	var settle, ttl time.Duration
+
+	if queryCache.SettleInterval != "" {
+		d, err := time.ParseDuration(queryCache.SettleInterval)
+		if err != nil {
+			return nil, errInit("QueryCache.SettleInterval needs to be a valid duration")
+		}
+		settle = d
+	}
+
+	if queryCache.TTL != "" {
+		d, err := time.ParseDuration(queryCache.TTL)
+		if err != nil {
+			return nil, errInit("QueryCache.TTL needs to be a valid duration")
+		}
+		ttl = d
+	}
+
+	if queryCache.MaxPoints > 0 {
+		if settle <= 0 {
+			return nil, errInit("QueryCache.SettleInterval needs to be positive when the cache is enabled")
+		}
+		if ttl <= 0 || ttl > cacheMaxTTL {
+			return nil, errInit(fmt.Sprintf("QueryCache.TTL needs to be between 0 and %v when the cache is enabled", cacheMaxTTL))
+		}
+	}
+
 	return &Plot{
 		esIndex:           esIndex,
+		cache:             newBucketCache(queryCache.MaxPoints, settle, ttl),
+		lastSeenMargin:    int64(lsi / time.Millisecond),
 		MaxTimeseries:     maxTimeseries,
 		LogQueryThreshold: logQueryTSthreshold,
+		slowQuery:         slow,
+		maxQuery:          maxQuery,
 		boltc:             bc,
+		limiter:           lim,
 		persist:           persistence{cassandra: cass, esTs: es, consistencies: consist},
 		concTimeseries:    make(chan struct{}, maxConcurrentTimeseries),
 		concReads:         make(chan struct{}, maxConcurrentReads),
 	}, nil
 }
 
+// queryContext is canceled when the client goes away or when the request
+// takes longer than MaxQueryDuration
+func (plot *Plot) queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if plot.maxQuery > 0 {
+		return context.WithTimeout(r.Context(), plot.maxQuery)
+	}
+	return context.WithCancel(r.Context())
+}
+
+// Consistencies returns the consistencies points are read with
+func (plot *Plot) Consistencies() []gocql.Consistency {
+	return plot.persist.consistencies
+}
+
+// ConcurrentReads returns the reads running and the concurrency limit
+func (plot *Plot) ConcurrentReads() (int, int) {
+	return len(plot.concReads), cap(plot.concReads)
+}
+
 func (plot *Plot) SetConsistencies(consistencies []gocql.Consistency) {
 	plot.persist.SetConsistencies(consistencies)
 }
@@ -67,7 +160,12 @@ type Plot struct {
 	esIndex           string
 	MaxTimeseries     int
 	LogQueryThreshold int
+	slowQuery         time.Duration
+	maxQuery          time.Duration
+	lastSeenMargin    int64
+	cache             *bucketCache
 	boltc             *bcache.Bcache
+	limiter           *limiter.Limiter
 	persist           persistence
 	concTimeseries    chan struct{}
 	concReads         chan struct{}
@@ -48,6 +48,62 @@ func (plot Plot) ListTags(keyspace, esType, tagKey string, size, from int64) ([]
 	return tags, total, gerr
 }
 
+// MetaCardinality counts the series of a keyspace per metric and per tag key,
+// with the number of distinct values of each tag key
+func (plot Plot) MetaCardinality(keyspace, esType, metric string, size int) (Cardinality, int, gobol.Error) {
+
+	esQuery := EsCardinalityQuery{
+		Aggs: map[string]interface{}{
+			"metrics": map[string]interface{}{
+				"terms": map[string]interface{}{"field": "metric.raw", "size": size},
+			},
+			"tags": map[string]interface{}{
+				"nested": map[string]string{"path": "tagsNested"},
+				"aggs": map[string]interface{}{
+					"keys": map[string]interface{}{
+						"terms": map[string]interface{}{"field": "tagsNested.tagKey.raw", "size": size},
+						"aggs": map[string]interface{}{
+							"values": map[string]interface{}{
+								"cardinality": map[string]string{"field": "tagsNested.tagValue.raw"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if metric != "" {
+		esQuery.Query = &BoolWrapper{}
+		esQuery.Query.Bool.Must = append(esQuery.Query.Bool.Must, Term{
+			Term: map[string]string{"metric": metric},
+		})
+	}
+
+	var esResp EsResponseCardinality
+
+	gerr := plot.persist.ListESCardinality(keyspace, esType, esQuery, &esResp)
+	if gerr != nil {
+		return Cardinality{}, 0, gerr
+	}
+
+	card := Cardinality{
+		Metrics: []MetricCardinality{},
+		TagKeys: []TagCardinality{},
+		Other:   esResp.Aggregations.Metrics.SumOtherDocCount,
+	}
+
+	for _, b := range esResp.Aggregations.Metrics.Buckets {
+		card.Metrics = append(card.Metrics, MetricCardinality{Metric: b.Key, Series: b.DocCount})
+	}
+
+	for _, b := range esResp.Aggregations.Tags.Keys.Buckets {
+		card.TagKeys = append(card.TagKeys, TagCardinality{TagKey: b.Key, Series: b.DocCount, Values: b.Values.Value})
+	}
+
+	return card, esResp.Hits.Total, nil
+}
+
 func (plot Plot) ListMetrics(keyspace, esType, metricName string, size, from int64) ([]string, int, gobol.Error) {
 
 	var esQuery QueryWrapper
@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -15,6 +16,7 @@ const (
 )
 
 func (plot *Plot) GetTimeSeries(
+	ctx context.Context,
 	keyspace string,
 	keys []string,
 	start,
@@ -23,8 +25,12 @@ func (plot *Plot) GetTimeSeries(
 	tuuid,
 	ms,
 	keepEmpties bool,
+	qs *QueryStats,
 ) (serie TS, gerr gobol.Error) {
 
+	start -= opers.TimeShift
+	end -= opers.TimeShift
+
 	w := start
 
 	index := 0
@@ -49,9 +55,15 @@ func (plot *Plot) GetTimeSeries(
 
 	tsChan := make(chan TS, len(keys))
 
+	started := 0
+
 	for _, key := range keys {
-		plot.concTimeseries <- struct{}{}
+		if !acquire(ctx, plot.concTimeseries) {
+			break
+		}
+		started++
 		go plot.getTimeSerie(
+			ctx,
 			keyspace,
 			key,
 			buckets,
@@ -61,13 +73,14 @@ func (plot *Plot) GetTimeSeries(
 			ms,
 			keepEmpties,
 			opers,
+			qs,
 			tsChan,
 		)
 	}
 
 	j := 0
 
-	for range keys {
+	for i := 0; i < started; i++ {
 
 		t := <-tsChan
 		if t.gerr != nil {
@@ -81,6 +94,10 @@ func (plot *Plot) GetTimeSeries(
 		serie.Total += t.Total
 	}
 
+	if ctx.Err() != nil {
+		return TS{}, errCanceled("GetTimeSeries", ctx.Err())
+	}
+
 	if gerr != nil {
 		return TS{}, gerr
 	}
@@ -100,12 +117,24 @@ func (plot *Plot) GetTimeSeries(
 			}
 		case "rate":
 			if opers.Rate.Enabled && exec {
-				serie.Data = rate(opers.Rate.Options, serie.Data)
+				serie.Data = rate(opers.Rate, serie.Data)
 			}
 		case "filterValue":
 			if opers.FilterValue.Enabled && exec {
 				serie.Data = filterValues(opers.FilterValue, serie.Data)
 			}
+		case "movingAverage", "ewma", "rollingMax", "rollingPercentile":
+			if w, ok := opers.Windows[oper]; ok && exec {
+				serie.Data = window(oper, w, serie.Data)
+			}
+		case "zscore":
+			if opers.ZScore && exec {
+				serie.Data = zscore(serie.Data)
+			}
+		case "outliers":
+			if opers.Outliers > 0 && exec {
+				serie.Data = outliers(opers.Outliers, serie.Data)
+			}
 		}
 	}
 
@@ -114,10 +143,15 @@ func (plot *Plot) GetTimeSeries(
 	}
 	serie.Count = len(serie.Data)
 
+	for i := range serie.Data {
+		serie.Data[i].Date += opers.TimeShift
+	}
+
 	return serie, nil
 }
 
 func (plot *Plot) getTimeSerie(
+	ctx context.Context,
 	keyspace,
 	key string,
 	buckets []string,
@@ -127,6 +161,7 @@ func (plot *Plot) getTimeSerie(
 	ms,
 	keepEmpties bool,
 	opers structs.DataOperations,
+	qs *QueryStats,
 	tsChan chan TS,
 ) {
 
@@ -139,24 +174,36 @@ func (plot *Plot) getTimeSerie(
 
 	bucketChan := make(chan TS, chanSize)
 
+	started := 0
+
 	if len(buckets) > 0 {
 		for i, bucket := range buckets {
+			if !acquire(ctx, plot.concReads) {
+				break
+			}
+			started++
 			buckID := fmt.Sprintf("%v%v", bucket, key)
-			plot.concReads <- struct{}{}
-			go plot.getTimeSerieBucket(i, keyspace, buckID, start, end, tuuid, ms, bucketChan)
+			go plot.getTimeSerieBucket(ctx, i, keyspace, buckID, start, end, tuuid, ms, qs, bucketChan)
 		}
-	} else {
-		plot.concReads <- struct{}{}
-		go plot.getTimeSerieBucket(0, keyspace, key, start, end, tuuid, ms, bucketChan)
+	} else if acquire(ctx, plot.concReads) {
+		started++
+		go plot.getTimeSerieBucket(ctx, 0, keyspace, key, start, end, tuuid, ms, qs, bucketChan)
 	}
 
 	bucketList := make([]TS, chanSize)
 
-	for i := 0; i < chanSize; i++ {
+	for i := 0; i < started; i++ {
 		buck := <-bucketChan
 		bucketList[buck.index] = buck
 	}
 
+	if ctx.Err() != nil {
+		serie.gerr = errCanceled("getTimeSerie", ctx.Err())
+		tsChan <- serie
+		<-plot.concTimeseries
+		return
+	}
+
 	for _, bl := range bucketList {
 		if bl.gerr != nil {
 			serie.gerr = bl.gerr
@@ -184,13 +231,25 @@ func (plot *Plot) getTimeSerie(
 			return
 		case "rate":
 			if opers.Rate.Enabled {
-				serie.Data = rate(opers.Rate.Options, serie.Data)
+				serie.Data = rate(opers.Rate, serie.Data)
 			}
 		case "filterValue":
 			if opers.FilterValue.Enabled {
 				serie.Data = filterValues(opers.FilterValue, serie.Data)
 
 			}
+		case "movingAverage", "ewma", "rollingMax", "rollingPercentile":
+			if w, ok := opers.Windows[oper]; ok {
+				serie.Data = window(oper, w, serie.Data)
+			}
+		case "zscore":
+			if opers.ZScore {
+				serie.Data = zscore(serie.Data)
+			}
+		case "outliers":
+			if opers.Outliers > 0 {
+				serie.Data = outliers(opers.Outliers, serie.Data)
+			}
 		}
 	}
 
@@ -200,7 +259,19 @@ func (plot *Plot) getTimeSerie(
 	<-plot.concTimeseries
 }
 
+// acquire takes a slot of a concurrency channel, it gives up when the
+// query is canceled
+func acquire(ctx context.Context, conc chan struct{}) bool {
+	select {
+	case conc <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func (plot *Plot) getTimeSerieBucket(
+	ctx context.Context,
 	index int,
 	keyspace,
 	key string,
@@ -208,10 +279,13 @@ func (plot *Plot) getTimeSerieBucket(
 	end int64,
 	tuuid,
 	ms bool,
+	qs *QueryStats,
 	bucketChan chan TS,
 ) {
 
-	resultSet, count, gerr := plot.persist.GetTS(keyspace, key, start, end, tuuid, ms)
+	qs.addBucket()
+
+	resultSet, count, gerr := plot.getTS(ctx, keyspace, key, start, end, tuuid, ms, qs)
 
 	bucketChan <- TS{
 		index: index,
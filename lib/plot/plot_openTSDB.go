@@ -1,7 +1,9 @@
 package plot
 
 import (
+	"bytes"
 	"strings"
+	"unicode"
 
 	"github.com/uol/gobol"
 
@@ -59,6 +61,64 @@ func (plot Plot) GetGroups(filters []structs.TSDBfilter, tsobs []TSDBobj) (group
 	return groups
 }
 
+// explicitTags keeps only the timeseries whose tag keys are exactly the
+// filtered ones
+func explicitTags(filters []structs.TSDBfilter, tsobs []TSDBobj) []TSDBobj {
+
+	keys := map[string]bool{}
+
+	for _, filter := range filters {
+		if filter.Ftype != "not_key" {
+			keys[filter.Tagk] = true
+		}
+	}
+
+	explicit := []TSDBobj{}
+
+	for _, tsob := range tsobs {
+
+		if len(tsob.Tags) != len(keys) {
+			continue
+		}
+
+		match := true
+
+		for k := range tsob.Tags {
+			if !keys[k] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			explicit = append(explicit, tsob)
+		}
+	}
+
+	return explicit
+}
+
+// caseInsensitive turns every letter of a regular expression into a
+// character class with its lower and upper case forms
+func caseInsensitive(exp string) string {
+
+	var buf bytes.Buffer
+
+	for _, r := range exp {
+		lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+		if lower == upper {
+			buf.WriteRune(r)
+			continue
+		}
+		buf.WriteString("[")
+		buf.WriteRune(lower)
+		buf.WriteRune(upper)
+		buf.WriteString("]")
+	}
+
+	return buf.String()
+}
+
 func (plot *Plot) MetaOpenTSDB(
 	keyspace,
 	id,
@@ -158,7 +218,9 @@ func (plot *Plot) MetaFilterOpenTSDB(
 	id,
 	metric string,
 	filters []structs.TSDBfilter,
-	size int64,
+	size,
+	start,
+	end int64,
 ) ([]TSDBobj, int, gobol.Error) {
 
 	esType := "meta"
@@ -167,6 +229,30 @@ func (plot *Plot) MetaFilterOpenTSDB(
 		Size: size,
 	}
 
+	// last seen is only updated every MetaLastSeenInterval, series without
+	// first and last seen were indexed before they were tracked
+	if start > 0 {
+		esQuery.Query.Bool.Must = append(esQuery.Query.Bool.Must, BoolWrapper{
+			Bool: OperatorWrapper{
+				Should: []interface{}{
+					EsRange{Range: map[string]EsRangeValue{"lastSeen": {Gte: start - plot.lastSeenMargin}}},
+					esMissing("lastSeen"),
+				},
+			},
+		})
+	}
+
+	if end > 0 {
+		esQuery.Query.Bool.Must = append(esQuery.Query.Bool.Must, BoolWrapper{
+			Bool: OperatorWrapper{
+				Should: []interface{}{
+					EsRange{Range: map[string]EsRangeValue{"firstSeen": {Lte: end}}},
+					esMissing("firstSeen"),
+				},
+			},
+		})
+	}
+
 	if metric != "" && metric != "*" {
 
 		metricTerm := Term{
@@ -196,6 +282,13 @@ func (plot *Plot) MetaFilterOpenTSDB(
 			},
 		}
 
+		esQueryNest.Nested.Query.Bool.Must = append(esQueryNest.Nested.Query.Bool.Must, tagKTerm)
+
+		if filter.Ftype == "not_key" {
+			esQuery.Query.Bool.MustNot = append(esQuery.Query.Bool.MustNot, esQueryNest)
+			continue
+		}
+
 		v := filter.Filter
 
 		if filter.Ftype != "regexp" {
@@ -204,8 +297,13 @@ func (plot *Plot) MetaFilterOpenTSDB(
 			v = strings.Replace(v, "#", "\\#", -1)
 		}
 
-		if filter.Ftype == "wildcard" {
+		switch filter.Ftype {
+		case "wildcard":
 			v = strings.Replace(v, "*", ".*", -1)
+		case "iwildcard":
+			v = caseInsensitive(strings.Replace(v, "*", ".*", -1))
+		case "iliteral_or", "not_iliteral_or":
+			v = caseInsensitive(v)
 		}
 
 		tagVTerm := EsRegexp{
@@ -214,9 +312,7 @@ func (plot *Plot) MetaFilterOpenTSDB(
 			},
 		}
 
-		esQueryNest.Nested.Query.Bool.Must = append(esQueryNest.Nested.Query.Bool.Must, tagKTerm)
-
-		if filter.Ftype == "not_literal_or" {
+		if filter.Ftype == "not_literal_or" || filter.Ftype == "not_iliteral_or" {
 			esQueryNest.Nested.Query.Bool.MustNot = append(esQueryNest.Nested.Query.Bool.MustNot, tagVTerm)
 		} else {
 			esQueryNest.Nested.Query.Bool.Must = append(esQueryNest.Nested.Query.Bool.Must, tagVTerm)
@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sort"
@@ -12,6 +13,7 @@ import (
 )
 
 func (plot *Plot) GetTextSeries(
+	ctx context.Context,
 	keyspace string,
 	keys []string,
 	start,
@@ -21,6 +23,7 @@ func (plot *Plot) GetTextSeries(
 	keepEmpties bool,
 	search *regexp.Regexp,
 	downsample structs.Downsample,
+	qs *QueryStats,
 ) (serie TST, gerr gobol.Error) {
 
 	w := start
@@ -47,14 +50,19 @@ func (plot *Plot) GetTextSeries(
 
 	tsChan := make(chan TST, len(keys))
 
+	started := 0
+
 	for _, key := range keys {
-		plot.concTimeseries <- struct{}{}
-		go plot.getTextSerie(keyspace, key, buckets, start, end, tuuid, keepEmpties, search, downsample, tsChan)
+		if !acquire(ctx, plot.concTimeseries) {
+			break
+		}
+		started++
+		go plot.getTextSerie(ctx, keyspace, key, buckets, start, end, tuuid, keepEmpties, search, downsample, qs, tsChan)
 	}
 
 	j := 0
 
-	for range keys {
+	for i := 0; i < started; i++ {
 
 		t := <-tsChan
 		if t.gerr != nil {
@@ -68,6 +76,10 @@ func (plot *Plot) GetTextSeries(
 		serie.Total = t.Total
 	}
 
+	if ctx.Err() != nil {
+		return TST{}, errCanceled("GetTextSeries", ctx.Err())
+	}
+
 	if gerr != nil {
 		return TST{}, gerr
 	}
@@ -83,6 +95,7 @@ func (plot *Plot) GetTextSeries(
 }
 
 func (plot *Plot) getTextSerie(
+	ctx context.Context,
 	keyspace,
 	key string,
 	buckets []string,
@@ -92,6 +105,7 @@ func (plot *Plot) getTextSerie(
 	keepEmpties bool,
 	search *regexp.Regexp,
 	downsample structs.Downsample,
+	qs *QueryStats,
 	tsChan chan TST,
 ) {
 
@@ -100,23 +114,36 @@ func (plot *Plot) getTextSerie(
 	chanSize := len(buckets)
 	bucketChan := make(chan TST, chanSize)
 
+	started := 0
+
 	for i, bucket := range buckets {
+		if !acquire(ctx, plot.concReads) {
+			break
+		}
+		started++
 		buckID := fmt.Sprintf("%v%v", bucket, key)
-		plot.concReads <- struct{}{}
-		go plot.getTextSerieBucket(i, keyspace, buckID, start, end, tuuid, search, downsample, bucketChan)
+		go plot.getTextSerieBucket(ctx, i, keyspace, buckID, start, end, tuuid, search, downsample, qs, bucketChan)
 	}
 
 	bucketList := make([]TST, chanSize)
 
-	for i := 0; i < chanSize; i++ {
+	for i := 0; i < started; i++ {
 		buck := <-bucketChan
 		bucketList[buck.index] = buck
 	}
 
+	if ctx.Err() != nil {
+		serie.gerr = errCanceled("getTextSerie", ctx.Err())
+		tsChan <- serie
+		<-plot.concTimeseries
+		return
+	}
+
 	for _, bl := range bucketList {
 		if bl.gerr != nil {
 			serie.gerr = bl.gerr
 			tsChan <- serie
+			<-plot.concTimeseries
 			return
 		}
 
@@ -132,6 +159,7 @@ func (plot *Plot) getTextSerie(
 }
 
 func (plot *Plot) getTextSerieBucket(
+	ctx context.Context,
 	index int,
 	keyspace,
 	key string,
@@ -140,10 +168,15 @@ func (plot *Plot) getTextSerieBucket(
 	tuuid bool,
 	search *regexp.Regexp,
 	downsample structs.Downsample,
+	qs *QueryStats,
 	tsChan chan TST,
 ) {
 
-	resultSet, count, gerr := plot.persist.GetTST(keyspace, key, start, end, tuuid, search)
+	qs.addBucket()
+
+	resultSet, count, gerr := plot.persist.GetTST(ctx, keyspace, key, start, end, tuuid, search)
+
+	qs.addRows(count)
 
 	tsChan <- TST{
 		index: index,
@@ -0,0 +1,127 @@
+package plot
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/uol/gobol"
+)
+
+// QueryStats accounts the resources used by a read request. Counters are
+// updated concurrently by the bucket readers. All methods accept a nil
+// receiver so accounting can be skipped
+type QueryStats struct {
+	Series     int64 `json:"series"`
+	EsHits     int64 `json:"esHits"`
+	Rows       int64 `json:"rows"`
+	Buckets    int64 `json:"buckets"`
+	DurationMs int64 `json:"durationMs"`
+
+	start     time.Time
+	threshold bool
+}
+
+type TSDBstatsSummary struct {
+	StatsSummary *QueryStats `json:"statsSummary"`
+}
+
+func newQueryStats() *QueryStats {
+	return &QueryStats{start: time.Now()}
+}
+
+func (qs *QueryStats) addSeries(n int) {
+	if qs != nil {
+		atomic.AddInt64(&qs.Series, int64(n))
+	}
+}
+
+func (qs *QueryStats) addEsHits(n int) {
+	if qs != nil {
+		atomic.AddInt64(&qs.EsHits, int64(n))
+	}
+}
+
+func (qs *QueryStats) addRows(n int) {
+	if qs != nil {
+		atomic.AddInt64(&qs.Rows, int64(n))
+	}
+}
+
+func (qs *QueryStats) exceeded() {
+	if qs != nil {
+		qs.threshold = true
+	}
+}
+
+func (qs *QueryStats) addBucket() {
+	if qs != nil {
+		atomic.AddInt64(&qs.Buckets, 1)
+	}
+}
+
+// add sums the rows and buckets of a single serie, series and hits are
+// accounted by the caller
+func (qs *QueryStats) add(s *QueryStats) {
+	if qs != nil && s != nil {
+		atomic.AddInt64(&qs.Rows, atomic.LoadInt64(&s.Rows))
+		atomic.AddInt64(&qs.Buckets, atomic.LoadInt64(&s.Buckets))
+	}
+}
+
+func (qs *QueryStats) finish() *QueryStats {
+	if qs != nil {
+		qs.DurationMs = int64(time.Since(qs.start) / time.Millisecond)
+	}
+	return qs
+}
+
+// withSummary appends the OpenTSDB statsSummary object to a query response
+func withSummary(resps TSDBresponses, qs *QueryStats) []interface{} {
+
+	out := make([]interface{}, 0, len(resps)+1)
+
+	for _, resp := range resps {
+		out = append(out, resp)
+	}
+
+	return append(out, TSDBstatsSummary{StatsSummary: qs.finish()})
+}
+
+// logQuery writes the slow query log. A query is logged when it takes longer
+// than SlowQueryThreshold or matches more than LogQueryTSthreshold series
+func (plot *Plot) logQuery(keyspace, f string, query interface{}, qs *QueryStats, gerr gobol.Error) {
+
+	qs.finish()
+
+	slow := plot.slowQuery > 0 && time.Duration(qs.DurationMs)*time.Millisecond > plot.slowQuery
+
+	if !slow && !qs.threshold {
+		return
+	}
+
+	statsSlowQuery(keyspace)
+
+	lf := logrus.Fields{
+		"func":       f,
+		"keyspace":   keyspace,
+		"series":     qs.Series,
+		"esHits":     qs.EsHits,
+		"rows":       qs.Rows,
+		"buckets":    qs.Buckets,
+		"durationMs": qs.DurationMs,
+		"slow":       slow,
+		"threshold":  qs.threshold,
+	}
+
+	if payload, err := json.Marshal(query); err == nil {
+		lf["query"] = string(payload)
+	}
+
+	if gerr != nil {
+		lf["error"] = gerr.Message()
+	}
+
+	gblog.WithFields(lf).Warn("slow query")
+}
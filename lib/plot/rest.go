@@ -10,6 +10,7 @@ import (
 	"github.com/uol/gobol/rip"
 
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -17,7 +18,7 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/points", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ListPoints"))
+		tserr.Fail(w, errNotFound("ListPoints"))
 		return
 	}
 
@@ -25,12 +26,12 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 	strTUUID, found, gerr := plot.boltc.GetKeyspace(keyspace)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if !found {
 		gerr := errNotFound("ListPoints")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -42,12 +43,28 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 	query := structs.TsQuery{}
 
-	err := rip.FromJSON(r, &query)
-	if err != nil {
-		rip.Fail(w, gerr)
+	gerr = rip.FromJSON(r, &query)
+	if gerr != nil {
+		tserr.Fail(w, errUnmarshal("ListPoints", gerr))
 		return
 	}
 
+	summary, _ := strconv.ParseBool(r.URL.Query().Get("showSummary"))
+
+	release, gerr := plot.limiter.AcquireQuery(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+	defer release()
+
+	ctx, cancel := plot.queryContext(r)
+	defer cancel()
+
+	qs := newQueryStats()
+
+	defer plot.logQuery(keyspace, "ListPoints", query, qs, nil)
+
 	mts := make(map[string]*Series)
 
 	empty := 0
@@ -56,6 +73,8 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 		key := []string{k.TSid}
 
+		qs.addSeries(len(key))
+
 		opers := structs.DataOperations{
 			Downsample: query.Downsample,
 			Order: []string{
@@ -66,6 +85,7 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 		}
 
 		sPoints, gerr := plot.GetTimeSeries(
+			ctx,
 			keyspace,
 			key,
 			query.Start,
@@ -74,9 +94,10 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 			tuuid,
 			true,
 			true,
+			qs,
 		)
 		if gerr != nil {
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if sPoints.Count == 0 {
@@ -119,7 +140,10 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 		key := []string{k.TSid}
 
+		qs.addSeries(len(key))
+
 		sPoints, gerr := plot.GetTextSeries(
+			ctx,
 			keyspace,
 			key,
 			query.Start,
@@ -129,10 +153,11 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 			true,
 			query.GetRe(),
 			query.Downsample,
+			qs,
 		)
 
 		if gerr != nil {
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if sPoints.Count == 0 {
@@ -181,10 +206,13 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 			}
 
+			qs.addSeries(len(ids))
+
 			sPoints := SeriesType{}
 
 			if ks.Keys[0].TSid[:1] == "T" {
 				serie, gerr := plot.GetTextSeries(
+					ctx,
 					keyspace,
 					ids,
 					query.Start,
@@ -194,9 +222,10 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 					true,
 					query.GetRe(),
 					query.Downsample,
+					qs,
 				)
 				if gerr != nil {
-					rip.Fail(w, gerr)
+					tserr.Fail(w, gerr)
 					return
 				}
 
@@ -232,6 +261,7 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 				}
 
 				serie, gerr := plot.GetTimeSeries(
+					ctx,
 					keyspace,
 					ids,
 					query.Start,
@@ -240,9 +270,10 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 					tuuid,
 					true,
 					true,
+					qs,
 				)
 				if gerr != nil {
-					rip.Fail(w, gerr)
+					tserr.Fail(w, gerr)
 					return
 				}
 
@@ -284,7 +315,7 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 
 	if len(query.Keys)+len(query.Text)+len(query.Merge) == empty {
 		gerr := errNoContent("ListPoints")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -292,6 +323,10 @@ func (plot *Plot) ListPoints(w http.ResponseWriter, r *http.Request, ps httprout
 		Payload: mts,
 	}
 
+	if summary {
+		out.Summary = qs.finish()
+	}
+
 	rip.SuccessJSON(w, http.StatusOK, out)
 	return
 }
@@ -310,7 +345,7 @@ func (plot *Plot) listTags(w http.ResponseWriter, r *http.Request, ps httprouter
 	if keyspace == "" {
 		smap["keyspace"] = "empty"
 		rip.AddStatsMap(r, smap)
-		rip.Fail(w, errNotFound("listTags"))
+		tserr.Fail(w, errNotFound("listTags"))
 		return
 	}
 
@@ -328,13 +363,13 @@ func (plot *Plot) listTags(w http.ResponseWriter, r *http.Request, ps httprouter
 		size, err = strconv.Atoi(sizeStr)
 		if err != nil {
 			gerr := errParamSize("ListTags", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 
 		if size <= 0 {
 			gerr := errParamSize("ListTags", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -347,24 +382,24 @@ func (plot *Plot) listTags(w http.ResponseWriter, r *http.Request, ps httprouter
 		from, err = strconv.Atoi(fromStr)
 		if err != nil {
 			gerr := errParamFrom("ListTags", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if from < 0 {
 			gerr := errParamFrom("ListTags", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
 
 	tags, total, gerr := plot.ListTags(keyspace, esType, q.Get("tag"), int64(size), int64(from))
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(tags) == 0 {
 		gerr := errNoContent("ListTags")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -391,7 +426,7 @@ func (plot *Plot) listMetrics(w http.ResponseWriter, r *http.Request, ps httprou
 	if keyspace == "" {
 		smap["keyspace"] = "empty"
 		rip.AddStatsMap(r, smap)
-		rip.Fail(w, errNotFound("listMetrics"))
+		tserr.Fail(w, errNotFound("listMetrics"))
 		return
 	}
 
@@ -408,12 +443,12 @@ func (plot *Plot) listMetrics(w http.ResponseWriter, r *http.Request, ps httprou
 		size, err = strconv.Atoi(sizeStr)
 		if err != nil {
 			gerr := errParamSize("ListMetrics", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if size <= 0 {
 			gerr := errParamSize("ListMetrics", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -426,24 +461,24 @@ func (plot *Plot) listMetrics(w http.ResponseWriter, r *http.Request, ps httprou
 		from, err = strconv.Atoi(fromStr)
 		if err != nil {
 			gerr := errParamFrom("ListMetrics", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if from < 0 {
 			gerr := errParamFrom("ListMetrics", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
 
 	metrics, total, gerr := plot.ListMetrics(keyspace, esType, q.Get("metric"), int64(size), int64(from))
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(metrics) == 0 {
 		gerr := errNoContent("ListMetrics")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -456,6 +491,59 @@ func (plot *Plot) listMetrics(w http.ResponseWriter, r *http.Request, ps httprou
 	return
 }
 
+func (plot *Plot) Cardinality(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyspace := ps.ByName("keyspace")
+	if keyspace == "" {
+		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/cardinality", "keyspace": "empty"})
+		tserr.Fail(w, errNotFound("Cardinality"))
+		return
+	}
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/cardinality", "keyspace": keyspace})
+
+	q := r.URL.Query()
+
+	size := 50
+
+	if sizeStr := q.Get("size"); sizeStr != "" {
+		s, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			tserr.Fail(w, errParamSize("Cardinality", err))
+			return
+		}
+		if s <= 0 {
+			tserr.Fail(w, errParamSize("Cardinality", errors.New("")))
+			return
+		}
+		size = s
+	}
+
+	_, found, gerr := plot.boltc.GetKeyspace(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+	if !found {
+		tserr.Fail(w, errNotFound("Cardinality"))
+		return
+	}
+
+	card, total, gerr := plot.MetaCardinality(keyspace, "meta", q.Get("metric"), size)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	out := Response{
+		TotalRecords: total,
+		Payload:      card,
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, out)
+	return
+}
+
 func (plot *Plot) ListMetaNumber(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	plot.listMeta(w, r, ps, "meta", map[string]string{"path": "/keyspaces/#keyspace/meta"})
 }
@@ -470,7 +558,7 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 	if keyspace == "" {
 		smap["keyspace"] = "empty"
 		rip.AddStatsMap(r, smap)
-		rip.Fail(w, errNotFound("listMeta"))
+		tserr.Fail(w, errNotFound("listMeta"))
 		return
 	}
 
@@ -482,7 +570,7 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 
 	gerr := rip.FromJSON(r, &query)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -494,11 +582,11 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 	if sizeStr != "" {
 		size, err = strconv.Atoi(sizeStr)
 		if err != nil {
-			rip.Fail(w, errParamSize("ListMeta", err))
+			tserr.Fail(w, errParamSize("ListMeta", err))
 			return
 		}
 		if size <= 0 {
-			rip.Fail(w, errParamSize("ListMeta", errors.New("")))
+			tserr.Fail(w, errParamSize("ListMeta", errors.New("")))
 			return
 		}
 	}
@@ -511,12 +599,12 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 		from, err = strconv.Atoi(fromStr)
 		if err != nil {
 			gerr := errParamFrom("ListMeta", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if from < 0 {
 			gerr := errParamFrom("ListMeta", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -529,7 +617,7 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 		onlyids, err = strconv.ParseBool(onlyidsStr)
 		if err != nil {
 			gerr := errValidation("ListMeta", `query param "onlyids" should be a boolean`, err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -542,12 +630,12 @@ func (plot *Plot) listMeta(w http.ResponseWriter, r *http.Request, ps httprouter
 
 	keys, total, gerr := plot.ListMeta(keyspace, esType, query.Metric, tags, onlyids, int64(size), int64(from))
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(keys) == 0 {
 		gerr := errNoContent("ListMeta")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
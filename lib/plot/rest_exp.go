@@ -10,8 +10,10 @@ import (
 	"github.com/uol/gobol"
 	"github.com/uol/gobol/rip"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/parser"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (plot *Plot) ExpressionCheckPOST(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -20,7 +22,7 @@ func (plot *Plot) ExpressionCheckPOST(w http.ResponseWriter, r *http.Request, _
 
 	gerr := rip.FromJSON(r, &expQuery)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -40,7 +42,7 @@ func (plot *Plot) ExpressionCheck(w http.ResponseWriter, expQuery ExpQuery) {
 
 	if expQuery.Expression == "" {
 		gerr := errEmptyExpression("ExpressionCheck")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -48,7 +50,7 @@ func (plot *Plot) ExpressionCheck(w http.ResponseWriter, expQuery ExpQuery) {
 
 	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -61,7 +63,7 @@ func (plot *Plot) ExpressionCheck(w http.ResponseWriter, expQuery ExpQuery) {
 
 	gerr = payload.Validate()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -75,7 +77,7 @@ func (plot *Plot) ExpressionQueryPOST(w http.ResponseWriter, r *http.Request, ps
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/query/expression", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ExpressionQueryPOST"))
+		tserr.Fail(w, errNotFound("ExpressionQueryPOST"))
 		return
 	}
 
@@ -85,7 +87,7 @@ func (plot *Plot) ExpressionQueryPOST(w http.ResponseWriter, r *http.Request, ps
 
 	gerr := rip.FromJSON(r, &expQuery)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -97,7 +99,7 @@ func (plot *Plot) ExpressionQueryGET(w http.ResponseWriter, r *http.Request, ps
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/query/expression", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ExpressionQueryGET"))
+		tserr.Fail(w, errNotFound("ExpressionQueryGET"))
 		return
 	}
 
@@ -114,25 +116,25 @@ func (plot *Plot) expressionQuery(w http.ResponseWriter, r *http.Request, keyspa
 
 	if expQuery.Expression == "" {
 		gerr := errEmptyExpression("expressionQuery")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	strTUUID, found, gerr := plot.boltc.GetKeyspace(keyspace)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if !found {
 		gerr := errNotFound("expressionQuery")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	tuuid, err := strconv.ParseBool(strTUUID)
 	if err != nil {
 		gerr := errValidationE("expressionQuery", err)
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -140,39 +142,70 @@ func (plot *Plot) expressionQuery(w http.ResponseWriter, r *http.Request, keyspa
 
 	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
-	tsuid := false
-	tsuidStr := r.URL.Query().Get("tsuid")
-	if tsuidStr != "" {
-		b, err := strconv.ParseBool(tsuidStr)
-		if err != nil {
-			gerr := errValidationE("expressionQuery", err)
-			rip.Fail(w, gerr)
-			return
+	flags := map[string]bool{}
+
+	for _, name := range []string{"tsuid", "showSummary", "showStats"} {
+		if str := r.URL.Query().Get(name); str != "" {
+			b, err := strconv.ParseBool(str)
+			if err != nil {
+				gerr := errValidationE("expressionQuery", err)
+				tserr.Fail(w, gerr)
+				return
+			}
+			flags[name] = b
 		}
-		tsuid = b
 	}
 
 	payload := structs.TSDBqueryPayload{
 		Queries: []structs.TSDBquery{
 			tsdb,
 		},
-		Relative:   relative,
-		ShowTSUIDs: tsuid,
+		Relative:    relative,
+		ShowTSUIDs:  flags["tsuid"],
+		ShowSummary: flags["showSummary"],
+		ShowStats:   flags["showStats"],
 	}
 
 	gerr = payload.Validate()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
-	resps, gerr := plot.getTimeseries(keyspace, tuuid, payload)
-	if err != nil {
-		rip.Fail(w, gerr)
+	release, gerr := plot.limiter.AcquireQuery(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+	defer release()
+
+	ctx, cancel := plot.queryContext(r)
+	defer cancel()
+
+	if stream := r.URL.Query().Get("stream"); stream != "" {
+		if stream != "json" && stream != "ndjson" {
+			tserr.Fail(w, errValidationS("expressionQuery", `query param "stream" should be json or ndjson`))
+			return
+		}
+		plot.streamTimeseries(ctx, w, keyspace, tuuid, payload, stream)
+		return
+	}
+
+	qs := newQueryStats()
+
+	resps, gerr := plot.getTimeseries(ctx, keyspace, tuuid, payload, qs)
+	plot.logQuery(keyspace, "expressionQuery", expQuery, qs, gerr)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	if payload.ShowSummary {
+		rip.SuccessJSON(w, http.StatusOK, withSummary(resps, qs))
 		return
 	}
 
@@ -191,7 +224,7 @@ func (plot *Plot) ExpressionParsePOST(w http.ResponseWriter, r *http.Request, _
 
 	gerr := rip.FromJSON(r, &expQuery)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -199,6 +232,13 @@ func (plot *Plot) ExpressionParsePOST(w http.ResponseWriter, r *http.Request, _
 		rip.AddStatsMap(r, map[string]string{"keyspace": expQuery.Keyspace})
 	}
 
+	if expQuery.Expand {
+		if gerr := auth.FromRequest(r).Authorize(expQuery.Keyspace, auth.Read); gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+	}
+
 	plot.expressionParse(w, expQuery)
 }
 
@@ -222,12 +262,19 @@ func (plot *Plot) ExpressionParseGET(w http.ResponseWriter, r *http.Request, _ h
 	expand, err := strconv.ParseBool(expandStr)
 	if err != nil {
 		gerr := errValidationE("ExpressionParseGET", err)
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	expQuery.Expand = expand
 
+	if expQuery.Expand {
+		if gerr := auth.FromRequest(r).Authorize(expQuery.Keyspace, auth.Read); gerr != nil {
+			tserr.Fail(w, gerr)
+			return
+		}
+	}
+
 	plot.expressionParse(w, expQuery)
 }
 
@@ -235,7 +282,7 @@ func (plot *Plot) expressionParse(w http.ResponseWriter, expQuery ExpParse) {
 
 	if expQuery.Expression == "" {
 		gerr := errEmptyExpression("expressionParse")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -243,18 +290,18 @@ func (plot *Plot) expressionParse(w http.ResponseWriter, expQuery ExpParse) {
 
 		if expQuery.Keyspace == "" {
 			gerr := errValidationS("expressionParse", `When expand true, Keyspace can not be empty`)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 
 		_, found, gerr := plot.boltc.GetKeyspace(expQuery.Keyspace)
 		if gerr != nil {
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if !found {
 			gerr := errValidationS("expressionParse", `keyspace not found`)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 
@@ -264,7 +311,7 @@ func (plot *Plot) expressionParse(w http.ResponseWriter, expQuery ExpParse) {
 
 	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -277,7 +324,7 @@ func (plot *Plot) expressionParse(w http.ResponseWriter, expQuery ExpParse) {
 
 	gerr = payload.Validate()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -288,7 +335,7 @@ func (plot *Plot) expressionParse(w http.ResponseWriter, expQuery ExpParse) {
 
 	payloadExp, gerr := plot.expandStruct(expQuery.Keyspace, payload)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -307,19 +354,19 @@ func (plot *Plot) ExpressionCompile(w http.ResponseWriter, r *http.Request, _ ht
 
 	gerr := rip.FromJSON(r, &tsdb)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	if tsdb.Relative == "" {
 		gerr := errValidationS("ExpressionCompile", "field relative can not be empty")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	if tsdb.Start != 0 || tsdb.End != 0 {
 		gerr := errValidationS("ExpressionCompile", "expression compile supports only relative times, start and end fields should be empty")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -335,7 +382,7 @@ func (plot *Plot) ExpressionExpandPOST(w http.ResponseWriter, r *http.Request, p
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/expression/expand", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ExpressionExpandPOST"))
+		tserr.Fail(w, errNotFound("ExpressionExpandPOST"))
 		return
 	}
 
@@ -343,7 +390,7 @@ func (plot *Plot) ExpressionExpandPOST(w http.ResponseWriter, r *http.Request, p
 
 	gerr := rip.FromJSON(r, &expQuery)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -359,7 +406,7 @@ func (plot *Plot) ExpressionExpandGET(w http.ResponseWriter, r *http.Request, ps
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/expression/expand", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ExpressionExpandGET"))
+		tserr.Fail(w, errNotFound("ExpressionExpandGET"))
 		return
 	}
 
@@ -372,18 +419,18 @@ func (plot *Plot) expressionExpand(w http.ResponseWriter, keyspace string, expQu
 
 	if expQuery.Expression == "" {
 		gerr := errEmptyExpression("expressionExpand")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	_, found, gerr := plot.boltc.GetKeyspace(keyspace)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if !found {
 		gerr := errNotFound("expressionExpand")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -391,7 +438,7 @@ func (plot *Plot) expressionExpand(w http.ResponseWriter, keyspace string, expQu
 
 	relative, gerr := parser.ParseExpression(expQuery.Expression, &tsdb)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -404,13 +451,13 @@ func (plot *Plot) expressionExpand(w http.ResponseWriter, keyspace string, expQu
 
 	gerr = payload.Validate()
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	payloadExp, gerr := plot.expandStruct(keyspace, payload)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -457,12 +504,14 @@ func (plot *Plot) expandStruct(
 			tsdb.Metric,
 			tsdb.Filters,
 			int64(10000),
+			0,
+			0,
 		)
 		if gerr != nil {
 			return groupQueries, gerr
 		}
 		if total > 10000 {
-			return groupQueries, errValidationS(
+			return groupQueries, errTooLarge(
 				"expandStruct",
 				fmt.Sprintf(
 					"expand exedded the maximum allowed number of timeseries. max is 10000 and the query returned %d",
@@ -1,6 +1,7 @@
 package plot
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/uol/mycenae/lib/parser"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -21,7 +23,7 @@ func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/search/lookup", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Lookup"))
+		tserr.Fail(w, errNotFound("Lookup"))
 		return
 	}
 
@@ -31,13 +33,13 @@ func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.P
 
 	if m == "" {
 		gerr := errValidationS("Lookup", `missing query parameter "m"`)
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	metric, tags, gerr := parseQuery(m)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -69,7 +71,7 @@ func (plot *Plot) Lookup(w http.ResponseWriter, r *http.Request, ps httprouter.P
 
 	tsds, total, gerr := plot.MetaOpenTSDB(keyspace, "", metric, tagMap, int64(10000), int64(0))
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -90,7 +92,7 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/suggest", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Suggest"))
+		tserr.Fail(w, errNotFound("Suggest"))
 		return
 	}
 
@@ -109,7 +111,7 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 		max, err = strconv.Atoi(maxStr)
 		if err != nil {
 			gerr := errValidationE("Suggest", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -119,7 +121,7 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 	switch q.Get("type") {
 	case "":
 		gerr = errValidationS("Suggest", "type required")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	case "metrics":
 		q := fmt.Sprintf("%v.*", q.Get("q"))
@@ -132,11 +134,11 @@ func (plot *Plot) Suggest(w http.ResponseWriter, r *http.Request, ps httprouter.
 		resp, _, gerr = plot.ListTagValue(keyspace, q, int64(max), int64(0))
 	default:
 		gerr = errValidationS("Suggest", "unsopported type")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -151,7 +153,7 @@ func (plot *Plot) Query(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 	keyspace := ps.ByName("keyspace")
 	if keyspace == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/query", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("Query"))
+		tserr.Fail(w, errNotFound("Query"))
 		return
 	}
 
@@ -159,19 +161,26 @@ func (plot *Plot) Query(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 
 	strTUUID, found, gerr := plot.boltc.GetKeyspace(keyspace)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if !found {
 		gerr := errNotFound("Query")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
 	tuuid, err := strconv.ParseBool(strTUUID)
 	if err != nil {
 		gerr := errValidationE("Query", err)
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	stream := r.URL.Query().Get("stream")
+
+	if stream != "" && stream != "json" && stream != "ndjson" {
+		tserr.Fail(w, errValidationS("Query", `query param "stream" should be json or ndjson`))
 		return
 	}
 
@@ -179,13 +188,36 @@ func (plot *Plot) Query(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 
 	gerr = rip.FromJSON(r, &query)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	release, gerr := plot.limiter.AcquireQuery(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
 		return
 	}
+	defer release()
 
-	resps, gerr := plot.getTimeseries(keyspace, tuuid, query)
+	ctx, cancel := plot.queryContext(r)
+	defer cancel()
+
+	if stream != "" {
+		plot.streamTimeseries(ctx, w, keyspace, tuuid, query, stream)
+		return
+	}
+
+	qs := newQueryStats()
+
+	resps, gerr := plot.getTimeseries(ctx, keyspace, tuuid, query, qs)
+	plot.logQuery(keyspace, "Query", query, qs, gerr)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	if query.ShowSummary {
+		rip.SuccessJSON(w, http.StatusOK, withSummary(resps, qs))
 		return
 	}
 
@@ -198,23 +230,244 @@ func (plot *Plot) Query(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 	return
 }
 
+func (plot *Plot) QueryExp(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyspace := ps.ByName("keyspace")
+	if keyspace == "" {
+		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/query/exp", "keyspace": "empty"})
+		tserr.Fail(w, errNotFound("QueryExp"))
+		return
+	}
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/query/exp", "keyspace": keyspace})
+
+	tuuid, gerr := plot.keyspaceTUUID(keyspace, "QueryExp")
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	query := TSDBexpQuery{}
+
+	gerr = rip.FromJSON(r, &query)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	gerr = query.Validate()
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	release, gerr := plot.limiter.AcquireQuery(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+	defer release()
+
+	ctx, cancel := plot.queryContext(r)
+	defer cancel()
+
+	qs := newQueryStats()
+
+	resp, gerr := plot.queryExp(ctx, keyspace, tuuid, query, qs)
+	plot.logQuery(keyspace, "QueryExp", query, qs, gerr)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, resp)
+	return
+}
+
+func (plot *Plot) QueryGexp(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	keyspace := ps.ByName("keyspace")
+	if keyspace == "" {
+		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/query/gexp", "keyspace": "empty"})
+		tserr.Fail(w, errNotFound("QueryGexp"))
+		return
+	}
+
+	rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/api/query/gexp", "keyspace": keyspace})
+
+	tuuid, gerr := plot.keyspaceTUUID(keyspace, "QueryGexp")
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	q := r.URL.Query()
+
+	exps := q["exp"]
+
+	if len(exps) == 0 {
+		tserr.Fail(w, errValidationS("QueryGexp", `missing query parameter "exp"`))
+		return
+	}
+
+	if q.Get("start") == "" {
+		tserr.Fail(w, errValidationS("QueryGexp", `missing query parameter "start"`))
+		return
+	}
+
+	now := time.Now()
+
+	start, gerr := parseTSDBtime(q.Get("start"), now)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	end, gerr := parseTSDBtime(q.Get("end"), now)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+
+	if end < start {
+		tserr.Fail(w, errValidationS("QueryGexp", "end date should be equal or bigger than start date"))
+		return
+	}
+
+	_, ms := q["ms"]
+
+	resps := TSDBresponses{}
+
+	release, gerr := plot.limiter.AcquireQuery(keyspace)
+	if gerr != nil {
+		tserr.Fail(w, gerr)
+		return
+	}
+	defer release()
+
+	ctx, cancel := plot.queryContext(r)
+	defer cancel()
+
+	qs := newQueryStats()
+
+	for _, exp := range exps {
+
+		series, gerr := plot.queryGexp(ctx, keyspace, tuuid, exp, start, end, qs)
+		if gerr != nil {
+			plot.logQuery(keyspace, "QueryGexp", exps, qs, gerr)
+			tserr.Fail(w, gerr)
+			return
+		}
+
+		for _, serie := range series {
+			resp := serie.response(ms, false)
+			if len(resp.Dps) > 0 {
+				resps = append(resps, resp)
+			}
+		}
+	}
+
+	plot.logQuery(keyspace, "QueryGexp", exps, qs, nil)
+
+	if len(resps) == 0 {
+		rip.SuccessJSON(w, http.StatusOK, []string{})
+		return
+	}
+
+	rip.SuccessJSON(w, http.StatusOK, resps)
+	return
+}
+
+func (plot *Plot) keyspaceTUUID(keyspace, f string) (bool, gobol.Error) {
+
+	strTUUID, found, gerr := plot.boltc.GetKeyspace(keyspace)
+	if gerr != nil {
+		return false, gerr
+	}
+	if !found {
+		return false, errNotFound(f)
+	}
+
+	tuuid, err := strconv.ParseBool(strTUUID)
+	if err != nil {
+		return false, errValidationE(f, err)
+	}
+
+	return tuuid, nil
+}
+
 func (plot *Plot) getTimeseries(
+	ctx context.Context,
 	keyspace string,
 	tuuid bool,
 	query structs.TSDBqueryPayload,
+	qs *QueryStats,
 ) (resps TSDBresponses, gerr gobol.Error) {
 
+	series, gerr := plot.getSeries(ctx, keyspace, tuuid, query, qs)
+	if gerr != nil {
+		return resps, gerr
+	}
+
+	for _, serie := range series {
+
+		resp := serie.response(query.MsResolution, query.ShowTSUIDs)
+
+		if query.ShowStats {
+			resp.Stats = serie.stats
+		}
+
+		if len(resp.Dps) > 0 {
+			resps = append(resps, resp)
+		}
+	}
+
+	sort.Sort(resps)
+
+	return resps, nil
+}
+
+func (plot *Plot) getSeries(
+	ctx context.Context,
+	keyspace string,
+	tuuid bool,
+	query structs.TSDBqueryPayload,
+	qs *QueryStats,
+) (series []tsdbSerie, gerr gobol.Error) {
+
+	gerr = plot.walkSeries(ctx, keyspace, tuuid, query, qs, func(serie tsdbSerie, gerr gobol.Error) gobol.Error {
+		if gerr != nil {
+			return gerr
+		}
+		series = append(series, serie)
+		return nil
+	})
+
+	return series, gerr
+}
+
+// walkSeries calls emit for every serie as soon as it is fetched, or with the
+// error of a serie that could not be read. It stops when emit returns an error
+func (plot *Plot) walkSeries(
+	ctx context.Context,
+	keyspace string,
+	tuuid bool,
+	query structs.TSDBqueryPayload,
+	qs *QueryStats,
+	emit func(tsdbSerie, gobol.Error) gobol.Error,
+) gobol.Error {
+
 	if query.Relative != "" {
 		now := time.Now()
 		start, gerr := parser.GetRelativeStart(now, query.Relative)
 		if gerr != nil {
-			return resps, gerr
+			return gerr
 		}
 		query.Start = start.UnixNano() / 1e+6
 		query.End = now.UnixNano() / 1e+6
 	} else {
 		if query.Start == 0 {
-			return resps, errValidationS("getTimeseries", "start cannot be zero")
+			return errValidationS("getTimeseries", "start cannot be zero")
 		}
 
 		if query.End == 0 {
@@ -222,7 +475,7 @@ func (plot *Plot) getTimeseries(
 		}
 
 		if query.End < query.Start {
-			return resps, errValidationS("getTimeseries", "end date should be equal or bigger than start date")
+			return errValidationS("getTimeseries", "end date should be equal or bigger than start date")
 		}
 	}
 
@@ -230,6 +483,20 @@ func (plot *Plot) getTimeseries(
 
 	for _, q := range query.Queries {
 
+		if ctx.Err() != nil {
+			return errCanceled("walkSeries", ctx.Err())
+		}
+
+		series := []tsdbSerie{}
+
+		add := func(serie tsdbSerie) gobol.Error {
+			if q.Rank != nil {
+				series = append(series, serie)
+				return nil
+			}
+			return emit(serie, nil)
+		}
+
 		if q.Downsample != "" {
 
 			ds := strings.Split(q.Downsample, "-")
@@ -317,19 +584,43 @@ func (plot *Plot) getTimeseries(
 			}
 		}
 
-		tsobs, total, gerr := plot.MetaFilterOpenTSDB(keyspace, "", m, q.Filters, int64(plot.MaxTimeseries))
+		var shift int64
+
+		if q.TimeShift != "" {
+			shifted, gerr := parser.GetRelativeStart(msToTime(query.Start), q.TimeShift)
+			if gerr != nil {
+				return gerr
+			}
+			shift = query.Start - timeToMs(shifted)
+		}
+
+		tsobs, total, gerr := plot.MetaFilterOpenTSDB(
+			keyspace,
+			"",
+			m,
+			q.Filters,
+			int64(plot.MaxTimeseries),
+			query.Start-shift,
+			query.End-shift,
+		)
 		if gerr != nil {
-			return resps, gerr
+			return gerr
 		}
 
+		qs.addEsHits(total)
+
 		if total > plot.LogQueryThreshold {
 			statsQueryThreshold(keyspace)
-			gblog.Warnf("TS THRESHOLD EXEECED: %+v", query)
+			qs.exceeded()
+		}
+
+		if max := plot.limiter.MaxQuerySeries(keyspace); max > 0 && total > max {
+			return plot.limiter.QuerySeriesExceeded(keyspace, max, total)
 		}
 
 		if total > plot.MaxTimeseries {
 			statsQueryLimit(keyspace)
-			return TSDBresponses{}, errValidationS(
+			return errTooLarge(
 				"getTimeseries",
 				fmt.Sprintf(
 					"query exedded the maximum allowed number of timeseries. max is %d and the query returned %d",
@@ -339,10 +630,30 @@ func (plot *Plot) getTimeseries(
 			)
 		}
 
+		// ranking part of the timeseries would give a wrong topk or bottomk
+		if q.Rank != nil && len(tsobs) < total {
+			statsQueryLimit(keyspace)
+			return errTooLarge(
+				"getTimeseries",
+				fmt.Sprintf(
+					"%s needs every timeseries of the query. %d of %d were returned",
+					q.Rank.Type,
+					len(tsobs),
+					total,
+				),
+			)
+		}
+
+		if q.ExplicitTags {
+			tsobs = explicitTags(q.Filters, tsobs)
+		}
+
 		if len(tsobs) == 0 {
 			continue
 		}
 
+		qs.addSeries(len(tsobs))
+
 		groups := plot.GetGroups(q.Filters, tsobs)
 
 		for _, group := range groups {
@@ -367,10 +678,22 @@ func (plot *Plot) getTimeseries(
 
 			aggTags := []string{}
 
-			if q.RateOptions.CounterMax == nil {
-				var maxInt int64
-				maxInt = 1<<63 - 1
-				q.RateOptions.CounterMax = &maxInt
+			rateUnit := int64(1000)
+
+			if q.RateOptions.Unit != "" {
+				rateUnit, gerr = durationMs(query.End, q.RateOptions.Unit)
+				if gerr != nil {
+					return gerr
+				}
+			}
+
+			var rateWindow int64
+
+			if q.RateOptions.Window != "" {
+				rateWindow, gerr = durationMs(query.End, q.RateOptions.Window)
+				if gerr != nil {
+					return gerr
+				}
 			}
 
 			filterV := structs.FilterValueOperation{}
@@ -380,14 +703,14 @@ func (plot *Plot) getTimeseries(
 				if q.FilterValue[:2] == ">=" || q.FilterValue[:2] == "<=" || q.FilterValue[:2] == "==" {
 					val, err := strconv.ParseFloat(q.FilterValue[2:], 64)
 					if err != nil {
-						return resps, errValidationE("getTimeseries", err)
+						return errValidationE("getTimeseries", err)
 					}
 					filterV.BoolOper = q.FilterValue[:2]
 					filterV.Value = val
 				} else if q.FilterValue[:1] == ">" || q.FilterValue[:1] == "<" {
 					val, err := strconv.ParseFloat(q.FilterValue[1:], 64)
 					if err != nil {
-						return resps, errValidationE("getTimeseries", err)
+						return errValidationE("getTimeseries", err)
 					}
 					filterV.BoolOper = q.FilterValue[:1]
 					filterV.Value = val
@@ -406,9 +729,19 @@ func (plot *Plot) getTimeseries(
 				Rate: structs.RateOperation{
 					Enabled: q.Rate,
 					Options: q.RateOptions,
+					Unit:    rateUnit,
+					Window:  rateWindow,
 				},
 				FilterValue: filterV,
 				Order:       q.Order,
+				Windows:     map[string]structs.WindowOperation{},
+				ZScore:      q.ZScore,
+				Outliers:    q.Outliers,
+				TimeShift:   shift,
+			}
+
+			for name, w := range q.Windows() {
+				opers.Windows[name] = windowOperation(*w)
 			}
 
 			keepEmpty := false
@@ -417,7 +750,29 @@ func (plot *Plot) getTimeseries(
 				keepEmpty = true
 			}
 
+			for k, kv := range tagK {
+				if len(kv) > 1 {
+					aggTags = append(aggTags, k)
+				}
+			}
+
+			sort.Strings(aggTags)
+
+			tagsU := make(map[string]string)
+
+			for k, kv := range tagK {
+				if len(kv) == 1 {
+					for v := range kv {
+						tagsU[k] = v
+					}
+				}
+			}
+
+			sqs := newQueryStats()
+			sqs.addSeries(len(ids))
+
 			serie, gerr := plot.GetTimeSeries(
+				ctx,
 				keyspace,
 				ids,
 				query.Start,
@@ -426,77 +781,121 @@ func (plot *Plot) getTimeseries(
 				tuuid,
 				query.MsResolution,
 				keepEmpty,
+				sqs,
 			)
-			if gerr != nil {
-				return resps, gerr
+
+			qs.add(sqs.finish())
+
+			if gerr != nil && ctx.Err() != nil {
+				return gerr
 			}
 
-			for k, kv := range tagK {
-				if len(kv) > 1 {
-					aggTags = append(aggTags, k)
+			if gerr != nil {
+				gerr = emit(tsdbSerie{
+					Metric:         q.Metric,
+					Tags:           tagsU,
+					AggregatedTags: aggTags,
+					Tsuids:         ids,
+					stats:          sqs,
+				}, gerr)
+				if gerr != nil {
+					return gerr
 				}
+				continue
 			}
 
-			sort.Strings(aggTags)
-
-			points := map[string]interface{}{}
+			if q.HoltWinters == nil {
+				gerr = add(tsdbSerie{
+					Metric:         q.Metric,
+					Tags:           tagsU,
+					AggregatedTags: aggTags,
+					Tsuids:         ids,
+					Fill:           oldDs.Options.Fill,
+					Data:           serie.Data,
+					stats:          sqs,
+				})
+				if gerr != nil {
+					return gerr
+				}
+				continue
+			}
 
-			for _, point := range serie.Data {
+			for band, data := range holtWinters(*q.HoltWinters, serie.Data) {
 
-				k := point.Date
+				tags := map[string]string{"holtWinters": band}
 
-				if !query.MsResolution {
-					k = point.Date / 1000
+				for k, v := range tagsU {
+					tags[k] = v
 				}
 
-				ksrt := strconv.FormatInt(k, 10)
-				if point.Empty {
-					switch oldDs.Options.Fill {
-					case "null":
-						points[ksrt] = nil
-					case "nan":
-						points[ksrt] = "NaN"
-					default:
-						points[ksrt] = point.Value
-					}
-				} else {
-					points[ksrt] = point.Value
+				gerr = add(tsdbSerie{
+					Metric:         q.Metric,
+					Tags:           tags,
+					AggregatedTags: aggTags,
+					Tsuids:         ids,
+					Fill:           oldDs.Options.Fill,
+					Data:           data,
+					stats:          sqs,
+				})
+				if gerr != nil {
+					return gerr
 				}
+			}
+		}
 
+		if q.Rank != nil {
+			for _, serie := range rank(*q.Rank, series) {
+				if gerr := emit(serie, nil); gerr != nil {
+					return gerr
+				}
 			}
+		}
 
-			if len(points) > 0 {
-				tagsU := make(map[string]string)
+	}
 
-				for k, kv := range tagK {
-					if len(kv) == 1 {
-						for v := range kv {
-							tagsU[k] = v
-						}
-					}
-				}
+	return nil
+}
 
-				resp := TSDBresponse{
-					Metric:         q.Metric,
-					Tags:           tagsU,
-					AggregatedTags: aggTags,
-					Dps:            points,
-				}
+func (serie tsdbSerie) response(msResolution, showTSUIDs bool) TSDBresponse {
 
-				if query.ShowTSUIDs {
-					resp.Tsuids = ids
-				}
+	points := map[string]interface{}{}
 
-				resps = append(resps, resp)
-			}
+	for _, point := range serie.Data {
 
+		k := point.Date
+
+		if !msResolution {
+			k = point.Date / 1000
+		}
+
+		ksrt := strconv.FormatInt(k, 10)
+		if point.Empty {
+			switch serie.Fill {
+			case "null":
+				points[ksrt] = nil
+			case "nan":
+				points[ksrt] = "NaN"
+			default:
+				points[ksrt] = point.Value
+			}
+		} else {
+			points[ksrt] = point.Value
 		}
 
 	}
 
-	sort.Sort(resps)
+	resp := TSDBresponse{
+		Metric:         serie.Metric,
+		Tags:           serie.Tags,
+		AggregatedTags: serie.AggregatedTags,
+		Dps:            points,
+	}
 
-	return resps, gerr
+	if showTSUIDs {
+		resp.Tsuids = serie.Tsuids
+	}
+
+	return resp
 }
 
 func parseQuery(query string) (string, []Tag, gobol.Error) {
@@ -568,3 +967,15 @@ func getTags(query string) ([]Tag, gobol.Error) {
 
 	return tags, nil
 }
+
+// durationMs is the length in milliseconds of a duration like 1h, measured
+// back from end so months and years have their calendar length
+func durationMs(end int64, d string) (int64, gobol.Error) {
+
+	start, gerr := parser.GetRelativeStart(msToTime(end), d)
+	if gerr != nil {
+		return 0, gerr
+	}
+
+	return end - timeToMs(start), nil
+}
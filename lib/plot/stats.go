@@ -18,6 +18,41 @@ func statsQueryLimit(ks string) {
 	)
 }
 
+func statsSlowQuery(ks string) {
+	go statsIncrement(
+		"mycenae.query.slow",
+		map[string]string{"keyspace": ks},
+	)
+}
+
+func statsCacheHit(ks string) {
+	go statsIncrement(
+		"mycenae.query.cache",
+		map[string]string{"keyspace": ks, "result": "hit"},
+	)
+}
+
+func statsCachePartial(ks string) {
+	go statsIncrement(
+		"mycenae.query.cache",
+		map[string]string{"keyspace": ks, "result": "partial"},
+	)
+}
+
+func statsCacheMiss(ks string) {
+	go statsIncrement(
+		"mycenae.query.cache",
+		map[string]string{"keyspace": ks, "result": "miss"},
+	)
+}
+
+func statsCacheInvalidation(ks string) {
+	go statsIncrement(
+		"mycenae.query.cache.invalidation",
+		map[string]string{"keyspace": ks},
+	)
+}
+
 func statsSelectQerror(ks, cf string) {
 	go statsIncrement(
 		"cassandra.query.error",
@@ -0,0 +1,149 @@
+package plot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+)
+
+// seriesStream writes query results one serie at a time, either as the
+// elements of a chunked JSON array or as newline delimited JSON
+type seriesStream struct {
+	w          http.ResponseWriter
+	enc        *json.Encoder
+	ndjson     bool
+	ms         bool
+	showTSUIDs bool
+	showStats  bool
+	started    bool
+	count      int
+}
+
+func newSeriesStream(w http.ResponseWriter, format string, query structs.TSDBqueryPayload) *seriesStream {
+	return &seriesStream{
+		w:          w,
+		enc:        json.NewEncoder(w),
+		ndjson:     format == "ndjson",
+		ms:         query.MsResolution,
+		showTSUIDs: query.ShowTSUIDs,
+		showStats:  query.ShowStats,
+	}
+}
+
+func (s *seriesStream) start() {
+
+	if s.started {
+		return
+	}
+
+	s.started = true
+
+	if s.ndjson {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		s.w.Header().Set("Content-Type", "application/json")
+	}
+
+	s.w.WriteHeader(http.StatusOK)
+
+	if !s.ndjson {
+		s.w.Write([]byte("["))
+	}
+}
+
+func (s *seriesStream) write(v interface{}) gobol.Error {
+
+	s.start()
+
+	if !s.ndjson && s.count > 0 {
+		if _, err := s.w.Write([]byte(",")); err != nil {
+			return errPersist("seriesStream", err)
+		}
+	}
+
+	if err := s.enc.Encode(v); err != nil {
+		return errPersist("seriesStream", err)
+	}
+
+	s.count++
+
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	return nil
+}
+
+func (s *seriesStream) serie(serie tsdbSerie, gerr gobol.Error) gobol.Error {
+
+	if gerr != nil {
+		return s.write(TSDBstreamError{
+			Metric: serie.Metric,
+			Tags:   serie.Tags,
+			Error: TSDBstreamErrorInfo{
+				Code:    gerr.StatusCode(),
+				Message: gerr.Message(),
+			},
+		})
+	}
+
+	resp := serie.response(s.ms, s.showTSUIDs)
+
+	if len(resp.Dps) == 0 {
+		return nil
+	}
+
+	if s.showStats {
+		resp.Stats = serie.stats
+	}
+
+	return s.write(resp)
+}
+
+// end closes the stream. Errors found before anything was written are
+// returned as a regular error response
+func (s *seriesStream) end(gerr gobol.Error) {
+
+	if gerr != nil {
+		if !s.started {
+			tserr.Fail(s.w, gerr)
+			return
+		}
+		s.serie(tsdbSerie{}, gerr)
+	}
+
+	s.start()
+
+	if !s.ndjson {
+		s.w.Write([]byte("]"))
+	}
+}
+
+func (plot *Plot) streamTimeseries(
+	ctx context.Context,
+	w http.ResponseWriter,
+	keyspace string,
+	tuuid bool,
+	query structs.TSDBqueryPayload,
+	format string,
+) {
+
+	stream := newSeriesStream(w, format, query)
+
+	qs := newQueryStats()
+
+	gerr := plot.walkSeries(ctx, keyspace, tuuid, query, qs, stream.serie)
+
+	plot.logQuery(keyspace, "streamTimeseries", query, qs, gerr)
+
+	if gerr == nil && query.ShowSummary {
+		gerr = stream.write(TSDBstatsSummary{StatsSummary: qs})
+	}
+
+	stream.end(gerr)
+}
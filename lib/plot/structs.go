@@ -1,21 +1,24 @@
 package plot
 
 import (
+	"encoding/json"
+	"fmt"
 	"regexp"
 	"sort"
-	//"fmt"
 	//"strings"
 	//"strconv"
 
 	"github.com/uol/gobol"
 	//"github.com/uol/mycenae/lib/config"
-	//"github.com/uol/mycenae/lib/structs"
+
+	"github.com/uol/mycenae/lib/structs"
 )
 
 var (
 	validFields = regexp.MustCompile(`^[0-9A-Za-z-._%&#;\\/]+$`)
 	validFwild  = regexp.MustCompile(`^[0-9A-Za-z-._%&#;\\/*]+$`)
 	validFor    = regexp.MustCompile(`^[0-9A-Za-z-._%&#;\\/|]+$`)
+	validExpID  = regexp.MustCompile(`^[A-Za-z_][0-9A-Za-z_]*$`)
 )
 
 type TsQuery struct {
@@ -190,6 +193,7 @@ type Response struct {
 	TotalRecords int         `json:"totalRecords,omitempty"`
 	Payload      interface{} `json:"payload,omitempty"`
 	Message      interface{} `json:"message,omitempty"`
+	Summary      *QueryStats `json:"summary,omitempty"`
 }
 
 type TS struct {
@@ -386,6 +390,58 @@ type MetaInfo struct {
 	Tags   []Tag  `json:"tagsNested"`
 }
 
+type EsCardinalityQuery struct {
+	Size  int                    `json:"size"`
+	Query *BoolWrapper           `json:"query,omitempty"`
+	Aggs  map[string]interface{} `json:"aggs"`
+}
+
+type EsResponseCardinality struct {
+	Hits         EsRespHitsWrapperMeta `json:"hits"`
+	Aggregations EsCardinalityAggs     `json:"aggregations"`
+}
+
+type EsCardinalityAggs struct {
+	Metrics EsTermsAgg       `json:"metrics"`
+	Tags    EsNestedTermsAgg `json:"tags"`
+}
+
+type EsNestedTermsAgg struct {
+	Keys EsTermsAgg `json:"keys"`
+}
+
+type EsTermsAgg struct {
+	SumOtherDocCount int            `json:"sum_other_doc_count"`
+	Buckets          []EsTermBucket `json:"buckets"`
+}
+
+type EsTermBucket struct {
+	Key      string       `json:"key"`
+	DocCount int          `json:"doc_count"`
+	Values   EsValueCount `json:"values"`
+}
+
+type EsValueCount struct {
+	Value int `json:"value"`
+}
+
+type Cardinality struct {
+	Metrics []MetricCardinality `json:"metrics"`
+	TagKeys []TagCardinality    `json:"tagKeys"`
+	Other   int                 `json:"otherMetricsSeries"`
+}
+
+type MetricCardinality struct {
+	Metric string `json:"metric"`
+	Series int    `json:"series"`
+}
+
+type TagCardinality struct {
+	TagKey string `json:"tagKey"`
+	Series int    `json:"series"`
+	Values int    `json:"values"`
+}
+
 type QueryWrapper struct {
 	Size   int64       `json:"size,omitempty"`
 	From   int64       `json:"from,omitempty"`
@@ -427,6 +483,33 @@ type Term struct {
 	Term map[string]string `json:"term"`
 }
 
+type EsRange struct {
+	Range map[string]EsRangeValue `json:"range"`
+}
+
+type EsRangeValue struct {
+	Gte int64 `json:"gte,omitempty"`
+	Lte int64 `json:"lte,omitempty"`
+}
+
+type EsExists struct {
+	Exists EsField `json:"exists"`
+}
+
+// esMissing matches the documents without field, the missing query was
+// removed in elasticsearch 5
+func esMissing(field string) BoolWrapper {
+	return BoolWrapper{
+		Bool: OperatorWrapper{
+			MustNot: []interface{}{EsExists{Exists: EsField{Field: field}}},
+		},
+	}
+}
+
+type EsField struct {
+	Field string `json:"field"`
+}
+
 type TSDBfilter struct {
 	Ftype   string `json:"type"`
 	Tagk    string `json:"tagk"`
@@ -804,17 +887,16 @@ func (query TSDBqueryPayload) checkDuration(s string) gobol.Error {
 		if err != nil {
 			return errCheckDuration(err)
 		}
-		return nil
-	}
-
-	switch s[len(s)-1:] {
-	case "s", "m", "h", "d", "w", "n", "y":
-		n, err = strconv.Atoi(string(s[:len(s)-1]))
-		if err != nil {
-			return errCheckDuration(err)
+	} else {
+		switch s[len(s)-1:] {
+		case "s", "m", "h", "d", "w", "n", "y":
+			n, err = strconv.Atoi(string(s[:len(s)-1]))
+			if err != nil {
+				return errCheckDuration(err)
+			}
+		default:
+			return errCheckDuration(fmt.Errorf("Invalid unit"))
 		}
-	default:
-		return errCheckDuration(fmt.Errorf("Invalid unit"))
 	}
 
 	if n < 1 {
@@ -927,6 +1009,28 @@ type TSDBresponse struct {
 	AggregatedTags []string               `json:"aggregateTags"`
 	Tsuids         []string               `json:"tsuids,omitempty"`
 	Dps            map[string]interface{} `json:"dps"`
+	Stats          *QueryStats            `json:"stats,omitempty"`
+}
+
+type TSDBstreamError struct {
+	Metric string              `json:"metric,omitempty"`
+	Tags   map[string]string   `json:"tags,omitempty"`
+	Error  TSDBstreamErrorInfo `json:"error"`
+}
+
+type TSDBstreamErrorInfo struct {
+	Code    int         `json:"code"`
+	Message interface{} `json:"message"`
+}
+
+type tsdbSerie struct {
+	Metric         string
+	Tags           map[string]string
+	AggregatedTags []string
+	Tsuids         []string
+	Fill           string
+	Data           Pnts
+	stats          *QueryStats
 }
 
 type ExpParse struct {
@@ -946,3 +1050,197 @@ type ExpQuery struct {
 func (eq ExpQuery) Validate() gobol.Error {
 	return nil
 }
+
+type TSDBexpQuery struct {
+	Time        TSDBexpTime         `json:"time"`
+	Filters     []TSDBexpFilter     `json:"filters,omitempty"`
+	Metrics     []TSDBexpMetric     `json:"metrics"`
+	Expressions []TSDBexpExpression `json:"expressions,omitempty"`
+	Outputs     []TSDBexpOutput     `json:"outputs,omitempty"`
+}
+
+func (eq TSDBexpQuery) Validate() gobol.Error {
+
+	if eq.Time.Start == "" {
+		return errValidationS("QueryExp", "time.start is required")
+	}
+
+	if len(eq.Metrics) == 0 {
+		return errValidationS("QueryExp", "at least one metric should be present")
+	}
+
+	ids := map[string]bool{}
+
+	filters := map[string]bool{}
+
+	for _, f := range eq.Filters {
+		if !validExpID.MatchString(f.ID) {
+			return errValidationS("QueryExp", fmt.Sprintf("invalid filter id %q", f.ID))
+		}
+		filters[f.ID] = true
+	}
+
+	if eq.Time.Downsampler != nil {
+		if err := eq.Time.Downsampler.FillPolicy.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range eq.Metrics {
+		if !validExpID.MatchString(m.ID) {
+			return errValidationS("QueryExp", fmt.Sprintf("invalid metric id %q", m.ID))
+		}
+		if ids[m.ID] {
+			return errValidationS("QueryExp", fmt.Sprintf("duplicated id %q", m.ID))
+		}
+		if m.Filter != "" && !filters[m.Filter] {
+			return errValidationS("QueryExp", fmt.Sprintf("metric %q references unknown filter %q", m.ID, m.Filter))
+		}
+		if err := m.FillPolicy.validate(); err != nil {
+			return err
+		}
+		ids[m.ID] = true
+	}
+
+	for _, e := range eq.Expressions {
+		if !validExpID.MatchString(e.ID) {
+			return errValidationS("QueryExp", fmt.Sprintf("invalid expression id %q", e.ID))
+		}
+		if ids[e.ID] {
+			return errValidationS("QueryExp", fmt.Sprintf("duplicated id %q", e.ID))
+		}
+		switch e.Join.Operator {
+		case "", "intersection", "union":
+		default:
+			return errValidationS("QueryExp", fmt.Sprintf("invalid join operator %q", e.Join.Operator))
+		}
+		if err := e.FillPolicy.validate(); err != nil {
+			return err
+		}
+
+		node, gerr := parseExpArithmetic(e.Expr)
+		if gerr != nil {
+			return gerr
+		}
+
+		for _, v := range node.variables() {
+			if !ids[v] {
+				return errValidationS("QueryExp", fmt.Sprintf("expression %q references unknown id %q", e.ID, v))
+			}
+		}
+		ids[e.ID] = true
+	}
+
+	for _, o := range eq.Outputs {
+		if !ids[o.ID] {
+			return errValidationS("QueryExp", fmt.Sprintf("output references unknown id %q", o.ID))
+		}
+	}
+
+	return nil
+}
+
+type TSDBexpTime struct {
+	Start       TSDBtime            `json:"start"`
+	End         TSDBtime            `json:"end,omitempty"`
+	Aggregator  string              `json:"aggregator"`
+	Downsampler *TSDBexpDownsampler `json:"downsampler,omitempty"`
+	Rate        bool                `json:"rate,omitempty"`
+}
+
+// TSDBtime accepts OpenTSDB times given either as JSON numbers or strings
+type TSDBtime string
+
+func (t *TSDBtime) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*t = TSDBtime(s)
+		return nil
+	}
+	*t = TSDBtime(b)
+	return nil
+}
+
+type TSDBexpDownsampler struct {
+	Interval   string          `json:"interval"`
+	Aggregator string          `json:"aggregator"`
+	FillPolicy *TSDBfillPolicy `json:"fillPolicy,omitempty"`
+}
+
+type TSDBfillPolicy struct {
+	Policy string  `json:"policy"`
+	Value  float64 `json:"value,omitempty"`
+}
+
+func (fp *TSDBfillPolicy) validate() gobol.Error {
+	if fp == nil {
+		return nil
+	}
+	switch fp.Policy {
+	case "", "none", "nan", "null", "zero", "scalar":
+		return nil
+	}
+	return errValidationS("QueryExp", fmt.Sprintf("invalid fill policy %q", fp.Policy))
+}
+
+type TSDBexpFilter struct {
+	ID           string               `json:"id"`
+	Tags         []structs.TSDBfilter `json:"tags"`
+	ExplicitTags bool                 `json:"explicitTags,omitempty"`
+}
+
+type TSDBexpMetric struct {
+	ID         string          `json:"id"`
+	Metric     string          `json:"metric"`
+	Filter     string          `json:"filter,omitempty"`
+	Aggregator string          `json:"aggregator,omitempty"`
+	FillPolicy *TSDBfillPolicy `json:"fillPolicy,omitempty"`
+}
+
+type TSDBexpExpression struct {
+	ID         string          `json:"id"`
+	Expr       string          `json:"expr"`
+	Join       TSDBexpJoin     `json:"join"`
+	FillPolicy *TSDBfillPolicy `json:"fillPolicy,omitempty"`
+}
+
+type TSDBexpJoin struct {
+	Operator       string `json:"operator"`
+	UseQueryTags   bool   `json:"useQueryTags"`
+	IncludeAggTags bool   `json:"includeAggTags"`
+}
+
+type TSDBexpOutput struct {
+	ID    string `json:"id"`
+	Alias string `json:"alias,omitempty"`
+}
+
+type TSDBexpResponse struct {
+	Outputs []TSDBexpOutputResp `json:"outputs"`
+	Query   TSDBexpQuery        `json:"query"`
+}
+
+type TSDBexpOutputResp struct {
+	ID      string          `json:"id"`
+	Alias   string          `json:"alias,omitempty"`
+	Dps     [][]interface{} `json:"dps"`
+	DpsMeta TSDBexpDpsMeta  `json:"dpsMeta"`
+	Meta    []TSDBexpMeta   `json:"meta"`
+}
+
+type TSDBexpDpsMeta struct {
+	FirstTimestamp int64 `json:"firstTimestamp"`
+	LastTimestamp  int64 `json:"lastTimestamp"`
+	SetCount       int   `json:"setCount"`
+	Series         int   `json:"series"`
+}
+
+type TSDBexpMeta struct {
+	Index          int               `json:"index"`
+	Metrics        []string          `json:"metrics"`
+	CommonTags     map[string]string `json:"commonTags,omitempty"`
+	AggregatedTags []string          `json:"aggregatedTags,omitempty"`
+}
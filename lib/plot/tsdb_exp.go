@@ -0,0 +1,713 @@
+package plot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/parser"
+	"github.com/uol/mycenae/lib/structs"
+)
+
+var tsdbTimeLayouts = []string{
+	"2006/01/02-15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02-15:04",
+	"2006/01/02 15:04",
+	"2006/01/02",
+}
+
+// parseTSDBtime converts an OpenTSDB time (now, 1h-ago, unix seconds or
+// milliseconds and yyyy/MM/dd-HH:mm:ss dates) to milliseconds
+func parseTSDBtime(s string, now time.Time) (int64, gobol.Error) {
+
+	s = strings.TrimSpace(s)
+
+	if s == "" || s == "now" {
+		return now.UnixNano() / 1e+6, nil
+	}
+
+	if strings.HasSuffix(s, "-ago") {
+		rel := strings.TrimSuffix(s, "-ago")
+		if len(rel) < 2 {
+			return 0, errValidationS("parseTSDBtime", fmt.Sprintf("invalid relative time %q", s))
+		}
+		t, gerr := parser.GetRelativeStart(now, rel)
+		if gerr != nil {
+			return 0, gerr
+		}
+		return t.UnixNano() / 1e+6, nil
+	}
+
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) <= 10 {
+			return ts * 1000, nil
+		}
+		return ts, nil
+	}
+
+	for _, layout := range tsdbTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.UnixNano() / 1e+6, nil
+		}
+	}
+
+	return 0, errValidationS("parseTSDBtime", fmt.Sprintf("invalid time %q", s))
+}
+
+// expNode is a node of an arithmetic expression tree used by /api/query/exp
+type expNode struct {
+	oper  byte
+	ident string
+	value float64
+	left  *expNode
+	right *expNode
+}
+
+func (node *expNode) variables() []string {
+
+	if node == nil {
+		return nil
+	}
+
+	if node.oper == 0 {
+		if node.ident != "" {
+			return []string{node.ident}
+		}
+		return nil
+	}
+
+	return append(node.left.variables(), node.right.variables()...)
+}
+
+type expParser struct {
+	exp string
+	pos int
+}
+
+func parseExpArithmetic(exp string) (*expNode, gobol.Error) {
+
+	if strings.TrimSpace(exp) == "" {
+		return nil, errEmptyExpression("parseExpArithmetic")
+	}
+
+	p := &expParser{exp: exp}
+
+	node, gerr := p.parseSum()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	p.skipSpaces()
+
+	if p.pos != len(p.exp) {
+		return nil, p.fail("unexpected character")
+	}
+
+	return node, nil
+}
+
+func (p *expParser) fail(s string) gobol.Error {
+	return errValidationS(
+		"parseExpArithmetic",
+		fmt.Sprintf("%s at position %d in expression %q", s, p.pos, p.exp),
+	)
+}
+
+func (p *expParser) skipSpaces() {
+	for p.pos < len(p.exp) && p.exp[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *expParser) parseSum() (*expNode, gobol.Error) {
+
+	left, gerr := p.parseProduct()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos == len(p.exp) || (p.exp[p.pos] != '+' && p.exp[p.pos] != '-') {
+			return left, nil
+		}
+		oper := p.exp[p.pos]
+		p.pos++
+
+		right, gerr := p.parseProduct()
+		if gerr != nil {
+			return nil, gerr
+		}
+		left = &expNode{oper: oper, left: left, right: right}
+	}
+}
+
+func (p *expParser) parseProduct() (*expNode, gobol.Error) {
+
+	left, gerr := p.parseFactor()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	for {
+		p.skipSpaces()
+		if p.pos == len(p.exp) || (p.exp[p.pos] != '*' && p.exp[p.pos] != '/' && p.exp[p.pos] != '%') {
+			return left, nil
+		}
+		oper := p.exp[p.pos]
+		p.pos++
+
+		right, gerr := p.parseFactor()
+		if gerr != nil {
+			return nil, gerr
+		}
+		left = &expNode{oper: oper, left: left, right: right}
+	}
+}
+
+func (p *expParser) parseFactor() (*expNode, gobol.Error) {
+
+	p.skipSpaces()
+
+	if p.pos == len(p.exp) {
+		return nil, p.fail("unexpected end")
+	}
+
+	c := p.exp[p.pos]
+
+	switch {
+	case c == '(':
+		p.pos++
+		node, gerr := p.parseSum()
+		if gerr != nil {
+			return nil, gerr
+		}
+		p.skipSpaces()
+		if p.pos == len(p.exp) || p.exp[p.pos] != ')' {
+			return nil, p.fail("missing ')'")
+		}
+		p.pos++
+		return node, nil
+	case c == '-':
+		p.pos++
+		node, gerr := p.parseFactor()
+		if gerr != nil {
+			return nil, gerr
+		}
+		return &expNode{oper: '-', left: &expNode{}, right: node}, nil
+	case (c >= '0' && c <= '9') || c == '.':
+		start := p.pos
+		for p.pos < len(p.exp) && ((p.exp[p.pos] >= '0' && p.exp[p.pos] <= '9') || p.exp[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.exp[start:p.pos], 64)
+		if err != nil {
+			return nil, p.fail("invalid number")
+		}
+		return &expNode{value: v}, nil
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		start := p.pos
+		for p.pos < len(p.exp) {
+			c := p.exp[p.pos]
+			if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+				break
+			}
+			p.pos++
+		}
+		return &expNode{ident: p.exp[start:p.pos]}, nil
+	}
+
+	return nil, p.fail("unexpected character")
+}
+
+// expSerie is a timeseries being evaluated by an expression, NaN values are
+// points without data
+type expSerie struct {
+	metrics []string
+	tags    map[string]string
+	aggTags []string
+	dps     map[int64]float64
+}
+
+type expOperand struct {
+	scalar  bool
+	value   float64
+	series  []expSerie
+	tagKeys map[string]bool
+	fill    *TSDBfillPolicy
+}
+
+func newExpSerie(serie tsdbSerie) expSerie {
+
+	es := expSerie{
+		metrics: []string{serie.Metric},
+		tags:    serie.Tags,
+		aggTags: serie.AggregatedTags,
+		dps:     make(map[int64]float64, len(serie.Data)),
+	}
+
+	for _, p := range serie.Data {
+		if p.Empty {
+			es.dps[p.Date] = math.NaN()
+		} else {
+			es.dps[p.Date] = p.Value
+		}
+	}
+
+	return es
+}
+
+func (es expSerie) joinKey(tagKeys map[string]bool, join TSDBexpJoin) string {
+
+	keys := []string{}
+
+	for k, v := range es.tags {
+		if join.UseQueryTags && tagKeys != nil && !tagKeys[k] {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(keys)
+
+	if join.IncludeAggTags {
+		aggs := append([]string{}, es.aggTags...)
+		sort.Strings(aggs)
+		keys = append(keys, aggs...)
+	}
+
+	return strings.Join(keys, ",")
+}
+
+func fillValue(fp *TSDBfillPolicy) (float64, bool) {
+
+	if fp == nil {
+		return 0, false
+	}
+
+	switch fp.Policy {
+	case "zero":
+		return 0, true
+	case "scalar":
+		return fp.Value, true
+	case "nan", "null":
+		return math.NaN(), true
+	}
+
+	return 0, false
+}
+
+func applyOper(oper byte, a, b float64) float64 {
+
+	switch oper {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	case '/':
+		if b == 0 {
+			return math.NaN()
+		}
+		return a / b
+	case '%':
+		if b == 0 {
+			return math.NaN()
+		}
+		return math.Mod(a, b)
+	}
+
+	return math.NaN()
+}
+
+func combineExpSeries(oper byte, a, b expSerie, fa, fb *TSDBfillPolicy) expSerie {
+
+	result := expSerie{
+		metrics: append(append([]string{}, a.metrics...), b.metrics...),
+		tags:    map[string]string{},
+		dps:     map[int64]float64{},
+	}
+
+	aggs := map[string]bool{}
+
+	for _, k := range a.aggTags {
+		aggs[k] = true
+	}
+
+	for _, k := range b.aggTags {
+		aggs[k] = true
+	}
+
+	for k, v := range a.tags {
+		if bv, ok := b.tags[k]; ok && bv == v {
+			result.tags[k] = v
+		} else {
+			aggs[k] = true
+		}
+	}
+
+	for k := range b.tags {
+		if _, ok := result.tags[k]; !ok {
+			aggs[k] = true
+		}
+	}
+
+	for k := range aggs {
+		result.aggTags = append(result.aggTags, k)
+	}
+
+	sort.Strings(result.aggTags)
+
+	fillA, okA := fillValue(fa)
+	fillB, okB := fillValue(fb)
+
+	for date, va := range a.dps {
+		vb, ok := b.dps[date]
+		if !ok {
+			if !okB {
+				continue
+			}
+			vb = fillB
+		}
+		result.dps[date] = applyOper(oper, va, vb)
+	}
+
+	if okA {
+		for date, vb := range b.dps {
+			if _, ok := a.dps[date]; !ok {
+				result.dps[date] = applyOper(oper, fillA, vb)
+			}
+		}
+	}
+
+	return result
+}
+
+func (node *expNode) eval(vars map[string]expOperand, join TSDBexpJoin) (expOperand, gobol.Error) {
+
+	if node.oper == 0 {
+		if node.ident == "" {
+			return expOperand{scalar: true, value: node.value}, nil
+		}
+		op, ok := vars[node.ident]
+		if !ok {
+			return expOperand{}, errValidationS("evalExpression", fmt.Sprintf("unknown id %q", node.ident))
+		}
+		return op, nil
+	}
+
+	left, gerr := node.left.eval(vars, join)
+	if gerr != nil {
+		return expOperand{}, gerr
+	}
+
+	right, gerr := node.right.eval(vars, join)
+	if gerr != nil {
+		return expOperand{}, gerr
+	}
+
+	if left.scalar && right.scalar {
+		return expOperand{scalar: true, value: applyOper(node.oper, left.value, right.value)}, nil
+	}
+
+	if left.scalar || right.scalar {
+
+		op := left
+		if left.scalar {
+			op = right
+		}
+
+		result := expOperand{tagKeys: op.tagKeys, fill: op.fill}
+
+		for _, s := range op.series {
+
+			rs := expSerie{
+				metrics: s.metrics,
+				tags:    s.tags,
+				aggTags: s.aggTags,
+				dps:     make(map[int64]float64, len(s.dps)),
+			}
+
+			for date, v := range s.dps {
+				if left.scalar {
+					rs.dps[date] = applyOper(node.oper, left.value, v)
+				} else {
+					rs.dps[date] = applyOper(node.oper, v, right.value)
+				}
+			}
+
+			result.series = append(result.series, rs)
+		}
+
+		return result, nil
+	}
+
+	tagKeys := map[string]bool{}
+
+	for k := range left.tagKeys {
+		tagKeys[k] = true
+	}
+
+	for k := range right.tagKeys {
+		tagKeys[k] = true
+	}
+
+	result := expOperand{tagKeys: tagKeys, fill: left.fill}
+
+	rightIndex := map[string][]int{}
+
+	for i, s := range right.series {
+		k := s.joinKey(tagKeys, join)
+		rightIndex[k] = append(rightIndex[k], i)
+	}
+
+	matched := map[int]bool{}
+
+	empty := expSerie{dps: map[int64]float64{}}
+
+	for _, ls := range left.series {
+
+		idxs, ok := rightIndex[ls.joinKey(tagKeys, join)]
+		if !ok {
+			if join.Operator == "union" {
+				result.series = append(result.series, combineExpSeries(node.oper, ls, empty, left.fill, right.fill))
+			}
+			continue
+		}
+
+		for _, i := range idxs {
+			matched[i] = true
+			result.series = append(
+				result.series,
+				combineExpSeries(node.oper, ls, right.series[i], left.fill, right.fill),
+			)
+		}
+	}
+
+	if join.Operator == "union" {
+		for i, rs := range right.series {
+			if !matched[i] {
+				result.series = append(result.series, combineExpSeries(node.oper, empty, rs, left.fill, right.fill))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (plot *Plot) queryExp(
+	ctx context.Context,
+	keyspace string,
+	tuuid bool,
+	eq TSDBexpQuery,
+	qs *QueryStats,
+) (TSDBexpResponse, gobol.Error) {
+
+	now := time.Now()
+
+	start, gerr := parseTSDBtime(string(eq.Time.Start), now)
+	if gerr != nil {
+		return TSDBexpResponse{}, gerr
+	}
+
+	end, gerr := parseTSDBtime(string(eq.Time.End), now)
+	if gerr != nil {
+		return TSDBexpResponse{}, gerr
+	}
+
+	if end < start {
+		return TSDBexpResponse{}, errValidationS("QueryExp", "end date should be equal or bigger than start date")
+	}
+
+	filters := map[string][]structs.TSDBfilter{}
+	explicit := map[string]bool{}
+
+	for _, f := range eq.Filters {
+		filters[f.ID] = f.Tags
+		explicit[f.ID] = f.ExplicitTags
+	}
+
+	downsample := ""
+
+	if ds := eq.Time.Downsampler; ds != nil && ds.Interval != "" {
+		fill := "none"
+		if ds.FillPolicy != nil && ds.FillPolicy.Policy != "" && ds.FillPolicy.Policy != "scalar" {
+			fill = ds.FillPolicy.Policy
+		}
+		downsample = fmt.Sprintf("%s-%s-%s", ds.Interval, ds.Aggregator, fill)
+	}
+
+	vars := map[string]expOperand{}
+
+	for _, m := range eq.Metrics {
+
+		aggregator := m.Aggregator
+		if aggregator == "" {
+			aggregator = eq.Time.Aggregator
+		}
+
+		payload := structs.TSDBqueryPayload{
+			Start: start,
+			End:   end,
+			Queries: []structs.TSDBquery{
+				{
+					Aggregator:   aggregator,
+					Downsample:   downsample,
+					Metric:       m.Metric,
+					Tags:         map[string]string{},
+					Rate:         eq.Time.Rate,
+					Filters:      filters[m.Filter],
+					ExplicitTags: explicit[m.Filter],
+				},
+			},
+			MsResolution: true,
+		}
+
+		gerr := payload.Validate()
+		if gerr != nil {
+			return TSDBexpResponse{}, gerr
+		}
+
+		series, gerr := plot.getSeries(ctx, keyspace, tuuid, payload, qs)
+		if gerr != nil {
+			return TSDBexpResponse{}, gerr
+		}
+
+		op := expOperand{tagKeys: map[string]bool{}, fill: m.FillPolicy}
+
+		for _, f := range filters[m.Filter] {
+			op.tagKeys[f.Tagk] = true
+		}
+
+		for _, serie := range series {
+			op.series = append(op.series, newExpSerie(serie))
+		}
+
+		vars[m.ID] = op
+	}
+
+	for _, e := range eq.Expressions {
+
+		node, gerr := parseExpArithmetic(e.Expr)
+		if gerr != nil {
+			return TSDBexpResponse{}, gerr
+		}
+
+		op, gerr := node.eval(vars, e.Join)
+		if gerr != nil {
+			return TSDBexpResponse{}, gerr
+		}
+
+		if e.FillPolicy != nil {
+			op.fill = e.FillPolicy
+		}
+
+		vars[e.ID] = op
+	}
+
+	outputs := eq.Outputs
+
+	if len(outputs) == 0 {
+		for _, e := range eq.Expressions {
+			outputs = append(outputs, TSDBexpOutput{ID: e.ID})
+		}
+	}
+
+	resp := TSDBexpResponse{
+		Outputs: []TSDBexpOutputResp{},
+		Query:   eq,
+	}
+
+	for _, o := range outputs {
+		op, ok := vars[o.ID]
+		if !ok {
+			return TSDBexpResponse{}, errValidationS("QueryExp", fmt.Sprintf("output references unknown id %q", o.ID))
+		}
+		resp.Outputs = append(resp.Outputs, op.output(o))
+	}
+
+	return resp, nil
+}
+
+func (op expOperand) output(o TSDBexpOutput) TSDBexpOutputResp {
+
+	out := TSDBexpOutputResp{
+		ID:    o.ID,
+		Alias: o.Alias,
+		Dps:   [][]interface{}{},
+		Meta: []TSDBexpMeta{
+			{
+				Index:   0,
+				Metrics: []string{"timestamp"},
+			},
+		},
+	}
+
+	if op.scalar {
+		return out
+	}
+
+	dates := map[int64]bool{}
+
+	for i, s := range op.series {
+		for date := range s.dps {
+			dates[date] = true
+		}
+		out.Meta = append(out.Meta, TSDBexpMeta{
+			Index:          i + 1,
+			Metrics:        s.metrics,
+			CommonTags:     s.tags,
+			AggregatedTags: s.aggTags,
+		})
+	}
+
+	ordered := make([]int64, 0, len(dates))
+
+	for date := range dates {
+		ordered = append(ordered, date)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i] < ordered[j] })
+
+	fill, fillOK := fillValue(op.fill)
+
+	for _, date := range ordered {
+
+		row := make([]interface{}, len(op.series)+1)
+		row[0] = date
+
+		for i, s := range op.series {
+			v, ok := s.dps[date]
+			if !ok && fillOK {
+				v, ok = fill, true
+			}
+			if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+				row[i+1] = nil
+				continue
+			}
+			row[i+1] = v
+		}
+
+		out.Dps = append(out.Dps, row)
+	}
+
+	out.DpsMeta = TSDBexpDpsMeta{
+		SetCount: len(out.Dps),
+		Series:   len(op.series),
+	}
+
+	if len(ordered) > 0 {
+		out.DpsMeta.FirstTimestamp = ordered[0]
+		out.DpsMeta.LastTimestamp = ordered[len(ordered)-1]
+	}
+
+	return out
+}
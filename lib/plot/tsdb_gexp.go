@@ -0,0 +1,396 @@
+package plot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uol/gobol"
+
+	"github.com/uol/mycenae/lib/structs"
+)
+
+// queryGexp evaluates a graphite style expression (/api/query/gexp)
+func (plot *Plot) queryGexp(
+	ctx context.Context,
+	keyspace string,
+	tuuid bool,
+	exp string,
+	start, end int64,
+	qs *QueryStats,
+) ([]tsdbSerie, gobol.Error) {
+
+	exp = strings.TrimSpace(exp)
+
+	if exp == "" {
+		return nil, errEmptyExpression("queryGexp")
+	}
+
+	i := strings.Index(exp, "(")
+
+	if i < 0 || strings.ContainsAny(exp[:i], ":{") {
+		return plot.gexpMetric(ctx, keyspace, tuuid, exp, start, end, qs)
+	}
+
+	if exp[len(exp)-1] != ')' {
+		return nil, errValidationS("queryGexp", fmt.Sprintf("missing ')' at the end of %q", exp))
+	}
+
+	name := strings.TrimSpace(exp[:i])
+
+	args := splitGexpArgs(exp[i+1 : len(exp)-1])
+
+	if len(args) == 0 {
+		return nil, errValidationS("queryGexp", fmt.Sprintf("function %s needs at least one parameter", name))
+	}
+
+	switch name {
+	case "sumSeries", "diffSeries", "multiplySeries", "divideSeries":
+
+		series := []tsdbSerie{}
+
+		for _, arg := range args {
+			s, gerr := plot.queryGexp(ctx, keyspace, tuuid, arg, start, end, qs)
+			if gerr != nil {
+				return nil, gerr
+			}
+			series = append(series, s...)
+		}
+
+		if len(series) == 0 {
+			return series, nil
+		}
+
+		return []tsdbSerie{combineGexp(name, series)}, nil
+	}
+
+	series, gerr := plot.queryGexp(ctx, keyspace, tuuid, args[0], start, end, qs)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	switch name {
+	case "absolute":
+		for _, serie := range series {
+			for j := range serie.Data {
+				serie.Data[j].Value = math.Abs(serie.Data[j].Value)
+			}
+		}
+		return series, nil
+	case "scale":
+		if len(args) != 2 {
+			return nil, errValidationS("queryGexp", "scale needs 2 parameters: expression and factor")
+		}
+		factor, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, errValidationE("queryGexp", err)
+		}
+		for _, serie := range series {
+			for j := range serie.Data {
+				serie.Data[j].Value = serie.Data[j].Value * factor
+			}
+		}
+		return series, nil
+	case "alias":
+		if len(args) != 2 {
+			return nil, errValidationS("queryGexp", "alias needs 2 parameters: expression and name")
+		}
+		for j, serie := range series {
+			alias := args[1]
+			for k, v := range serie.Tags {
+				alias = strings.Replace(alias, "@"+k, v, -1)
+			}
+			series[j].Metric = alias
+		}
+		return series, nil
+	case "movingAverage":
+		if len(args) != 2 {
+			return nil, errValidationS("queryGexp", "movingAverage needs 2 parameters: expression and window")
+		}
+		for j, serie := range series {
+			data, gerr := gexpMovingAverage(serie.Data, args[1])
+			if gerr != nil {
+				return nil, gerr
+			}
+			series[j].Data = data
+		}
+		return series, nil
+	case "highestCurrent", "highestMax":
+		if len(args) != 2 {
+			return nil, errValidationS("queryGexp", fmt.Sprintf("%s needs 2 parameters: expression and number of series", name))
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return nil, errValidationS("queryGexp", fmt.Sprintf("invalid number of series %q", args[1]))
+		}
+		return highestGexp(name, series, n), nil
+	}
+
+	return nil, errValidationS("queryGexp", fmt.Sprintf("unknown function %s", name))
+}
+
+// gexpMetric parses an OpenTSDB metric query (agg:[interval-agg[-fill]:][rate:]metric{tags})
+func (plot *Plot) gexpMetric(
+	ctx context.Context,
+	keyspace string,
+	tuuid bool,
+	exp string,
+	start, end int64,
+	qs *QueryStats,
+) ([]tsdbSerie, gobol.Error) {
+
+	head, sub := getMetric(exp)
+
+	parts := strings.Split(head, ":")
+
+	if len(parts) < 2 {
+		return nil, errValidationS("gexpMetric", fmt.Sprintf("invalid metric query %q", exp))
+	}
+
+	q := structs.TSDBquery{
+		Aggregator: parts[0],
+		Metric:     parts[len(parts)-1],
+		Tags:       map[string]string{},
+	}
+
+	for _, p := range parts[1 : len(parts)-1] {
+		switch {
+		case p == "rate":
+			q.Rate = true
+		case strings.Contains(p, "-"):
+			q.Downsample = p
+		default:
+			return nil, errValidationS("gexpMetric", fmt.Sprintf("invalid metric query parameter %q", p))
+		}
+	}
+
+	tags, gerr := getTags(sub)
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	for _, tag := range tags {
+		q.Tags[tag.Key] = tag.Value
+	}
+
+	payload := structs.TSDBqueryPayload{
+		Start:        start,
+		End:          end,
+		Queries:      []structs.TSDBquery{q},
+		MsResolution: true,
+	}
+
+	gerr = payload.Validate()
+	if gerr != nil {
+		return nil, gerr
+	}
+
+	return plot.getSeries(ctx, keyspace, tuuid, payload, qs)
+}
+
+func splitGexpArgs(s string) []string {
+
+	args := []string{}
+
+	depth := 0
+	last := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, trimGexpArg(s[last:i]))
+				last = i + 1
+			}
+		}
+	}
+
+	if strings.TrimSpace(s) != "" {
+		args = append(args, trimGexpArg(s[last:]))
+	}
+
+	return args
+}
+
+func trimGexpArg(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `'"`)
+}
+
+func gexpMovingAverage(data Pnts, window string) (Pnts, gobol.Error) {
+
+	avg := make(Pnts, len(data))
+
+	if n, err := strconv.Atoi(window); err == nil {
+
+		if n < 1 {
+			return nil, errValidationS("gexpMovingAverage", "window should be greater than zero")
+		}
+
+		for i := range data {
+			var sum, count float64
+			for j := i; j >= 0 && j > i-n; j-- {
+				if !data[j].Empty {
+					sum += data[j].Value
+					count++
+				}
+			}
+			avg[i] = movingPoint(data[i].Date, sum, count)
+		}
+
+		return avg, nil
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return nil, errValidationS("gexpMovingAverage", fmt.Sprintf("invalid window %q", window))
+	}
+
+	ms := int64(d / time.Millisecond)
+
+	for i := range data {
+		var sum, count float64
+		for j := i; j >= 0 && data[j].Date > data[i].Date-ms; j-- {
+			if !data[j].Empty {
+				sum += data[j].Value
+				count++
+			}
+		}
+		avg[i] = movingPoint(data[i].Date, sum, count)
+	}
+
+	return avg, nil
+}
+
+func movingPoint(date int64, sum, count float64) Pnt {
+
+	if count == 0 {
+		return Pnt{Date: date, Empty: true}
+	}
+
+	return Pnt{Date: date, Value: sum / count}
+}
+
+func combineGexp(name string, series []tsdbSerie) tsdbSerie {
+
+	result := tsdbSerie{
+		Metric: series[0].Metric,
+		Tags:   map[string]string{},
+		Fill:   series[0].Fill,
+	}
+
+	aggs := map[string]bool{}
+
+	for k, v := range series[0].Tags {
+		common := true
+		for _, serie := range series[1:] {
+			if serie.Tags[k] != v {
+				common = false
+				break
+			}
+		}
+		if common {
+			result.Tags[k] = v
+		}
+	}
+
+	values := map[int64]float64{}
+	seen := map[int64]bool{}
+
+	for i, serie := range series {
+
+		for _, k := range serie.AggregatedTags {
+			aggs[k] = true
+		}
+
+		for k := range serie.Tags {
+			if _, ok := result.Tags[k]; !ok {
+				aggs[k] = true
+			}
+		}
+
+		for _, p := range serie.Data {
+
+			if p.Empty {
+				continue
+			}
+
+			if !seen[p.Date] {
+				// the first serie is the minuend and the dividend, dates it
+				// doesn't have are left out
+				if i > 0 && (name == "diffSeries" || name == "divideSeries") {
+					continue
+				}
+				seen[p.Date] = true
+				values[p.Date] = p.Value
+				continue
+			}
+
+			switch name {
+			case "sumSeries":
+				values[p.Date] += p.Value
+			case "diffSeries":
+				values[p.Date] -= p.Value
+			case "multiplySeries":
+				values[p.Date] *= p.Value
+			case "divideSeries":
+				if p.Value == 0 {
+					values[p.Date] = math.NaN()
+				} else {
+					values[p.Date] /= p.Value
+				}
+			}
+		}
+	}
+
+	for k := range aggs {
+		result.AggregatedTags = append(result.AggregatedTags, k)
+	}
+
+	sort.Strings(result.AggregatedTags)
+
+	for date, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			result.Data = append(result.Data, Pnt{Date: date, Empty: true})
+			continue
+		}
+		result.Data = append(result.Data, Pnt{Date: date, Value: v})
+	}
+
+	sort.Sort(result.Data)
+
+	return result
+}
+
+func highestGexp(name string, series []tsdbSerie, n int) []tsdbSerie {
+
+	score := func(serie tsdbSerie) float64 {
+		v := math.Inf(-1)
+		for _, p := range serie.Data {
+			if p.Empty {
+				continue
+			}
+			if name == "highestCurrent" || p.Value > v {
+				v = p.Value
+			}
+		}
+		return v
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		return score(series[i]) > score(series[j])
+	})
+
+	if len(series) > n {
+		series = series[:n]
+	}
+
+	return series
+}
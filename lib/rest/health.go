@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gocql/gocql"
+	"github.com/julienschmidt/httprouter"
+	"github.com/uol/gobol"
+	"github.com/uol/gobol/rip"
+)
+
+const (
+	statusOK        = "ok"
+	statusFail      = "fail"
+	statusSaturated = "saturated"
+	statusStopping  = "stopping"
+)
+
+type health struct {
+	Status     string                `json:"status"`
+	Components map[string]*component `json:"components,omitempty"`
+}
+
+type component struct {
+	Status string  `json:"status"`
+	Used   int     `json:"used,omitempty"`
+	Size   int     `json:"size,omitempty"`
+	Ratio  float64 `json:"ratio,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+type healthCheck func(ctx context.Context) *component
+
+func (trest *REST) live(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	rip.SuccessJSON(w, http.StatusOK, health{Status: statusOK})
+}
+
+// ready checks every dependency of the node. It fails while stopping, when
+// a dependency fails or doesn't answer within the probe timeout and when a
+// queue is used above its probe threshold
+func (trest *REST) ready(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), trest.probeTimeout)
+	defer cancel()
+
+	checks := trest.healthChecks()
+
+	type result struct {
+		name string
+		c    *component
+	}
+
+	results := make(chan result, len(checks))
+
+	for name, check := range checks {
+		go func(name string, check healthCheck) {
+			results <- result{name: name, c: check(ctx)}
+		}(name, check)
+	}
+
+	resp := health{
+		Status:     statusOK,
+		Components: make(map[string]*component, len(checks)),
+	}
+
+wait:
+	for range checks {
+		select {
+		case res := <-results:
+			resp.Components[res.name] = res.c
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for name := range checks {
+		if _, ok := resp.Components[name]; !ok {
+			resp.Components[name] = &component{Status: statusFail, Error: "check timed out"}
+		}
+	}
+
+	for _, c := range resp.Components {
+		if c.Status != statusOK {
+			resp.Status = statusFail
+		}
+	}
+
+	if trest.probeStatus != http.StatusOK {
+		resp.Status = statusStopping
+	}
+
+	status := http.StatusOK
+	if resp.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	rip.SuccessJSON(w, status, resp)
+}
+
+func (trest *REST) healthChecks() map[string]healthCheck {
+
+	checks := map[string]healthCheck{
+		"elasticsearch": func(context.Context) *component {
+			return pingComponent(trest.writer.PingES())
+		},
+		"bolt": func(context.Context) *component {
+			return pingComponent(trest.boltc.Ping())
+		},
+		"metaQueue": func(context.Context) *component {
+			return queueComponent(trest.writer.MetaQueue, trest.probe.MetaQueue)
+		},
+		"concurrentPoints": func(context.Context) *component {
+			return queueComponent(trest.writer.ConcurrentPoints, trest.probe.ConcurrentPoints)
+		},
+		"concurrentReads": func(context.Context) *component {
+			return queueComponent(trest.reader.ConcurrentReads, trest.probe.ConcurrentReads)
+		},
+	}
+
+	cassandra := func(op string, consistencies []gocql.Consistency) {
+		for _, cons := range consistencies {
+			cons := cons
+			name := "cassandra." + op + "." + strings.ToLower(cons.String())
+			checks[name] = func(ctx context.Context) *component {
+				return pingComponent(trest.kspace.Ping(ctx, cons))
+			}
+		}
+	}
+
+	cassandra("read", trest.reader.Consistencies())
+	cassandra("write", trest.writer.Consistencies())
+
+	for name, l := range trest.listeners {
+		l := l
+		checks[name] = func(context.Context) *component {
+			if l.Listening() {
+				return &component{Status: statusOK}
+			}
+			return &component{Status: statusFail, Error: "not listening"}
+		}
+	}
+
+	return checks
+}
+
+func pingComponent(gerr gobol.Error) *component {
+
+	if gerr != nil {
+		return &component{Status: statusFail, Error: gerr.Error()}
+	}
+
+	return &component{Status: statusOK}
+}
+
+// queueComponent compares how much of a queue is used with its threshold, a
+// threshold of zero is never exceeded
+func queueComponent(usage func() (int, int), threshold float64) *component {
+
+	used, size := usage()
+
+	c := &component{Status: statusOK, Used: used, Size: size}
+
+	if size > 0 {
+		c.Ratio = float64(used) / float64(size)
+	}
+
+	if threshold > 0 && c.Ratio > threshold {
+		c.Status = statusSaturated
+	}
+
+	return c
+}
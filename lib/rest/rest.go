@@ -6,18 +6,22 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol/rip"
 	"github.com/uol/gobol/snitch"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/bcache"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/config"
 	"github.com/uol/mycenae/lib/keyspace"
 	"github.com/uol/mycenae/lib/plot"
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tserr"
+	"github.com/uol/mycenae/lib/udp"
 	"github.com/uol/mycenae/lib/udpError"
 )
 
@@ -29,40 +33,58 @@ func New(
 	keyspace *keyspace.Keyspace,
 	bc *bcache.Bcache,
 	collector *collector.Collector,
+	au *auth.Auth,
+	listeners map[string]*udp.UDPserver,
 	set structs.SettingsHTTP,
-	probeThreshold float64,
-) *REST {
+	probe structs.SettingsProbe,
+) (*REST, error) {
 
-	return &REST{
-		probeThreshold: probeThreshold,
-		probeStatus:    http.StatusOK,
-		closed:         make(chan struct{}),
-
-		gblog:    log.General,
-		sts:      gbs,
-		reader:   p,
-		udperr:   ue,
-		kspace:   keyspace,
-		boltc:    bc,
-		writer:   collector,
-		settings: set,
+	timeout := 2 * time.Second
+
+	if probe.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(probe.Timeout)
+		if err != nil {
+			return nil, err
+		}
 	}
+
+	return &REST{
+		probe:        probe,
+		probeTimeout: timeout,
+		probeStatus:  http.StatusOK,
+		closed:       make(chan struct{}),
+
+		gblog:     log.General,
+		sts:       gbs,
+		reader:    p,
+		udperr:    ue,
+		kspace:    keyspace,
+		boltc:     bc,
+		writer:    collector,
+		auth:      au,
+		listeners: listeners,
+		settings:  set,
+	}, nil
 }
 
 type REST struct {
-	probeThreshold float64
-	probeStatus    int
-	closed         chan struct{}
-
-	gblog    *logrus.Logger
-	sts      *snitch.Stats
-	reader   *plot.Plot
-	udperr   *udpError.UDPerror
-	kspace   *keyspace.Keyspace
-	boltc    *bcache.Bcache
-	writer   *collector.Collector
-	settings structs.SettingsHTTP
-	server   *http.Server
+	probe        structs.SettingsProbe
+	probeTimeout time.Duration
+	probeStatus  int
+	closed       chan struct{}
+
+	gblog     *logrus.Logger
+	sts       *snitch.Stats
+	reader    *plot.Plot
+	udperr    *udpError.UDPerror
+	kspace    *keyspace.Keyspace
+	boltc     *bcache.Bcache
+	writer    *collector.Collector
+	auth      *auth.Auth
+	listeners map[string]*udp.UDPserver
+	settings  structs.SettingsHTTP
+	server    *http.Server
 }
 
 func (trest *REST) Start() {
@@ -74,6 +96,7 @@ func (trest *REST) Start() {
 func (trest *REST) asyncStart() {
 
 	rip.SetLooger(trest.gblog)
+	tserr.SetLogger(trest.gblog)
 
 	pathMatcher := regexp.MustCompile(`^(/[a-zA-Z0-9._-]+)?/$`)
 
@@ -90,47 +113,58 @@ func (trest *REST) asyncStart() {
 	router := rip.NewCustomRouter()
 	//PROBE
 	router.GET(path+"probe", trest.check)
+	router.GET(path+"health/live", trest.live)
+	router.GET(path+"health/ready", trest.ready)
+	router.GET(path+"stats/ingest", trest.writer.Ingest)
 	//READ
-	router.POST(path+"keyspaces/:keyspace/points", trest.reader.ListPoints)
+	router.POST(path+"keyspaces/:keyspace/points", trest.auth.Keyspace(auth.Read, trest.reader.ListPoints))
 	//EXPRESSION
-	router.GET(path+"expression/check", trest.reader.ExpressionCheckGET)
-	router.POST(path+"expression/check", trest.reader.ExpressionCheckPOST)
-	router.POST(path+"expression/compile", trest.reader.ExpressionCompile)
-	router.GET(path+"expression/parse", trest.reader.ExpressionParseGET)
-	router.POST(path+"expression/parse", trest.reader.ExpressionParsePOST)
-	router.GET(path+"keyspaces/:keyspace/expression/expand", trest.reader.ExpressionExpandGET)
-	router.POST(path+"keyspaces/:keyspace/expression/expand", trest.reader.ExpressionExpandPOST)
+	router.GET(path+"expression/check", trest.auth.Token(trest.reader.ExpressionCheckGET))
+	router.POST(path+"expression/check", trest.auth.Token(trest.reader.ExpressionCheckPOST))
+	router.POST(path+"expression/compile", trest.auth.Token(trest.reader.ExpressionCompile))
+	router.GET(path+"expression/parse", trest.auth.Token(trest.reader.ExpressionParseGET))
+	router.POST(path+"expression/parse", trest.auth.Token(trest.reader.ExpressionParsePOST))
+	router.GET(path+"keyspaces/:keyspace/expression/expand", trest.auth.Keyspace(auth.Read, trest.reader.ExpressionExpandGET))
+	router.POST(path+"keyspaces/:keyspace/expression/expand", trest.auth.Keyspace(auth.Read, trest.reader.ExpressionExpandPOST))
 	//NUMBER
-	router.GET(path+"keyspaces/:keyspace/tags", trest.reader.ListTagsNumber)
-	router.GET(path+"keyspaces/:keyspace/metrics", trest.reader.ListMetricsNumber)
-	router.POST(path+"keyspaces/:keyspace/meta", trest.reader.ListMetaNumber)
+	router.GET(path+"keyspaces/:keyspace/tags", trest.auth.Keyspace(auth.Read, trest.reader.ListTagsNumber))
+	router.GET(path+"keyspaces/:keyspace/metrics", trest.auth.Keyspace(auth.Read, trest.reader.ListMetricsNumber))
+	router.POST(path+"keyspaces/:keyspace/meta", trest.auth.Keyspace(auth.Read, trest.reader.ListMetaNumber))
+	router.GET(path+"keyspaces/:keyspace/cardinality", trest.auth.Keyspace(auth.Read, trest.reader.Cardinality))
 	//TEXT
-	router.GET(path+"keyspaces/:keyspace/text/tags", trest.reader.ListTagsText)
-	router.GET(path+"keyspaces/:keyspace/text/metrics", trest.reader.ListMetricsText)
-	router.POST(path+"keyspaces/:keyspace/text/meta", trest.reader.ListMetaText)
+	router.GET(path+"keyspaces/:keyspace/text/tags", trest.auth.Keyspace(auth.Read, trest.reader.ListTagsText))
+	router.GET(path+"keyspaces/:keyspace/text/metrics", trest.auth.Keyspace(auth.Read, trest.reader.ListMetricsText))
+	router.POST(path+"keyspaces/:keyspace/text/meta", trest.auth.Keyspace(auth.Read, trest.reader.ListMetaText))
 	//UDP ERROR
-	router.POST(path+"keyspaces/:keyspace/errortags", trest.udperr.ListErrorTags)
-	router.GET(path+"keyspaces/:keyspace/errors/:error", trest.udperr.GetErrorInfo)
+	router.POST(path+"keyspaces/:keyspace/errortags", trest.auth.Keyspace(auth.Read, trest.udperr.ListErrorTags))
+	router.GET(path+"keyspaces/:keyspace/errors/:error", trest.auth.Keyspace(auth.Read, trest.udperr.GetErrorInfo))
+	//DEAD LETTERS
+	router.GET(path+"keyspaces/:keyspace/deadletters", trest.auth.Keyspace(auth.Read, trest.writer.ListDeadLetters))
+	router.GET(path+"keyspaces/:keyspace/deadletters/reasons", trest.auth.Keyspace(auth.Read, trest.writer.DeadLetterReasons))
+	router.DELETE(path+"keyspaces/:keyspace/deadletters", trest.auth.Keyspace(auth.Admin, trest.writer.DeleteDeadLetters))
+	router.POST(path+"keyspaces/:keyspace/deadletters/replay", trest.auth.Keyspace(auth.Write, trest.writer.ReplayDeadLetters))
 	//KEYSPACE
-	router.GET(path+"datacenters", trest.kspace.ListDC)
-	router.HEAD(path+"keyspaces/:keyspace", trest.kspace.Check)
-	router.POST(path+"keyspaces/:keyspace", trest.kspace.Create)
-	router.PUT(path+"keyspaces/:keyspace", trest.kspace.Update)
-	router.GET(path+"keyspaces", trest.kspace.GetAll)
+	router.GET(path+"datacenters", trest.auth.Token(trest.kspace.ListDC))
+	router.HEAD(path+"keyspaces/:keyspace", trest.auth.Keyspace(auth.Read, trest.kspace.Check))
+	router.POST(path+"keyspaces/:keyspace", trest.auth.Global(auth.Admin, trest.kspace.Create))
+	router.PUT(path+"keyspaces/:keyspace", trest.auth.Keyspace(auth.Admin, trest.kspace.Update))
+	router.GET(path+"keyspaces", trest.auth.Global(auth.Admin, trest.kspace.GetAll))
 	//WRITE
-	router.POST(path+"api/put", trest.writer.Scollector)
-	router.PUT(path+"api/put", trest.writer.Scollector)
-	router.POST(path+"v2/points", trest.writer.Scollector)
-	router.POST(path+"v2/text", trest.writer.Text)
+	router.POST(path+"api/put", trest.auth.Token(trest.writer.Scollector))
+	router.PUT(path+"api/put", trest.auth.Token(trest.writer.Scollector))
+	router.POST(path+"v2/points", trest.auth.Token(trest.writer.Scollector))
+	router.POST(path+"v2/text", trest.auth.Token(trest.writer.Text))
 	//OPENTSDB
-	router.POST("/keyspaces/:keyspace/api/query", trest.reader.Query)
-	router.GET("/keyspaces/:keyspace/api/suggest", trest.reader.Suggest)
-	router.GET("/keyspaces/:keyspace/api/search/lookup", trest.reader.Lookup)
-	router.GET("/keyspaces/:keyspace/api/aggregators", config.Aggregators)
-	router.GET("/keyspaces/:keyspace/api/config/filters", config.Filters)
+	router.POST("/keyspaces/:keyspace/api/query", trest.auth.Keyspace(auth.Read, trest.reader.Query))
+	router.POST("/keyspaces/:keyspace/api/query/exp", trest.auth.Keyspace(auth.Read, trest.reader.QueryExp))
+	router.GET("/keyspaces/:keyspace/api/query/gexp", trest.auth.Keyspace(auth.Read, trest.reader.QueryGexp))
+	router.GET("/keyspaces/:keyspace/api/suggest", trest.auth.Keyspace(auth.Read, trest.reader.Suggest))
+	router.GET("/keyspaces/:keyspace/api/search/lookup", trest.auth.Keyspace(auth.Read, trest.reader.Lookup))
+	router.GET("/keyspaces/:keyspace/api/aggregators", trest.auth.Keyspace(auth.Read, config.Aggregators))
+	router.GET("/keyspaces/:keyspace/api/config/filters", trest.auth.Keyspace(auth.Read, config.Filters))
 	//HYBRIDS
-	router.POST("/keyspaces/:keyspace/query/expression", trest.reader.ExpressionQueryPOST)
-	router.GET("/keyspaces/:keyspace/query/expression", trest.reader.ExpressionQueryGET)
+	router.POST("/keyspaces/:keyspace/query/expression", trest.auth.Keyspace(auth.Read, trest.reader.ExpressionQueryPOST))
+	router.GET("/keyspaces/:keyspace/query/expression", trest.auth.Keyspace(auth.Read, trest.reader.ExpressionQueryGET))
 
 	trest.server = &http.Server{
 		Addr: fmt.Sprintf("%s:%s", trest.settings.Bind, trest.settings.Port),
@@ -157,7 +191,7 @@ func (trest *REST) check(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 
 	UDPup := trest.writer.CheckUDPbind()
 
-	if UDPup && ratio < trest.probeThreshold {
+	if UDPup && ratio < trest.probe.Threshold {
 		w.WriteHeader(trest.probeStatus)
 	} else {
 		w.WriteHeader(http.StatusInternalServerError)
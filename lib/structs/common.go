@@ -22,6 +22,45 @@ type SettingsHTTP struct {
 type SettingsUDP struct {
 	Port       string
 	ReadBuffer int
+	Sockets    int
+	Workers    int
+	QueueSize  int
+	DropPolicy string
+}
+
+type SettingsProbe struct {
+	Threshold        float64
+	Window           string
+	Timeout          string
+	MetaQueue        float64
+	ConcurrentPoints float64
+	ConcurrentReads  float64
+}
+
+type SettingsErrors struct {
+	Retention       string
+	CleanupInterval string
+	CleanupBatch    int
+	DeadLetterLimit int
+}
+
+type SettingsAuth struct {
+	Enabled    bool
+	UDPtoken   bool
+	HMACsecret string
+	Tokens     []SettingsToken
+}
+
+type SettingsToken struct {
+	Name   string
+	Token  string
+	Grants []string
+}
+
+type SettingsQueryCache struct {
+	MaxPoints      int
+	SettleInterval string
+	TTL            string
 }
 
 type Settings struct {
@@ -32,15 +71,24 @@ type Settings struct {
 	MaxConcurrentTimeseries int
 	MaxConcurrentReads      int
 	LogQueryTSthreshold     int
+	SlowQueryThreshold      string
+	MaxQueryDuration        string
+	QuotaRefreshInterval    string
 	MaxConcurrentPoints     int
+	MaxTags                 int
 	MaxConcurrentBulks      int
 	MaxMetaBulkSize         int
 	MetaBufferSize          int
 	MetaSaveInterval        string
+	MetaLastSeenInterval    string
+	ShutdownTimeout         string
 	CompactionStrategy      string
 	HTTPserver              SettingsHTTP
 	UDPserver               SettingsUDP
 	UDPserverV2             SettingsUDP
+	QueryCache              SettingsQueryCache
+	Auth                    SettingsAuth
+	Errors                  SettingsErrors
 	Cassandra               cassandra.Settings
 	TTL                     struct {
 		Max int
@@ -57,7 +105,5 @@ type Settings struct {
 		Cluster rubber.Settings
 		Index   string
 	}
-	Probe struct {
-		Threshold float64
-	}
+	Probe SettingsProbe
 }
@@ -11,9 +11,10 @@ import (
 
 func errBasic(f, s string, e error) gobol.Error {
 	if e != nil {
-		return tserr.New(
+		return tserr.NewCode(
 			e,
 			s,
+			tserr.InvalidQuery,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"package": "structs",
@@ -57,6 +58,18 @@ func errRate(s string) gobol.Error {
 	return errBasic("CheckRate", s, errors.New(s))
 }
 
+func errHoltWinters(s string) gobol.Error {
+	return errBasic("CheckHoltWinters", s, errors.New(s))
+}
+
+func errWindow(s string) gobol.Error {
+	return errBasic("CheckWindow", s, errors.New(s))
+}
+
+func errRank(s string) gobol.Error {
+	return errBasic("CheckRank", s, errors.New(s))
+}
+
 func errFilter(s string) gobol.Error {
 	return errBasic("CheckFilter", s, errors.New(s))
 }
@@ -19,15 +19,62 @@ var (
 )
 
 type TSDBquery struct {
-	Aggregator  string            `json:"aggregator"`
-	Downsample  string            `json:"downsample,omitempty"`
-	Metric      string            `json:"metric"`
-	Tags        map[string]string `json:"tags"`
-	Rate        bool              `json:"rate,omitempty"`
-	RateOptions TSDBrateOptions   `json:"rateOptions,omitempty"`
-	Order       []string          `json:"order,omitempty"`
-	FilterValue string            `json:"filterValue,omitempty"`
-	Filters     []TSDBfilter      `json:"filters,omitempty"`
+	Aggregator   string            `json:"aggregator"`
+	Downsample   string            `json:"downsample,omitempty"`
+	Metric       string            `json:"metric"`
+	Tags         map[string]string `json:"tags"`
+	Rate         bool              `json:"rate,omitempty"`
+	RateOptions  TSDBrateOptions   `json:"rateOptions,omitempty"`
+	Order        []string          `json:"order,omitempty"`
+	FilterValue  string            `json:"filterValue,omitempty"`
+	Filters      []TSDBfilter      `json:"filters,omitempty"`
+	Rank         *TSDBrank         `json:"rank,omitempty"`
+	TimeShift    string            `json:"timeShift,omitempty"`
+	ExplicitTags bool              `json:"explicitTags,omitempty"`
+	ZScore       bool              `json:"zscore,omitempty"`
+	Outliers     float64           `json:"outliers,omitempty"`
+	HoltWinters  *TSDBholtWinters  `json:"holtWinters,omitempty"`
+
+	MovingAverage     *TSDBwindow `json:"movingAverage,omitempty"`
+	Ewma              *TSDBwindow `json:"ewma,omitempty"`
+	RollingMax        *TSDBwindow `json:"rollingMax,omitempty"`
+	RollingPercentile *TSDBwindow `json:"rollingPercentile,omitempty"`
+}
+
+// PointOrder lists the point functions applied after rate in their default order
+var PointOrder = []string{"movingAverage", "ewma", "rollingMax", "rollingPercentile", "zscore", "outliers"}
+
+// Windows returns the configured moving window functions by order name
+func (q TSDBquery) Windows() map[string]*TSDBwindow {
+
+	windows := map[string]*TSDBwindow{}
+
+	for name, w := range map[string]*TSDBwindow{
+		"movingAverage":     q.MovingAverage,
+		"ewma":              q.Ewma,
+		"rollingMax":        q.RollingMax,
+		"rollingPercentile": q.RollingPercentile,
+	} {
+		if w != nil {
+			windows[name] = w
+		}
+	}
+
+	return windows
+}
+
+func (q TSDBquery) pointFunctions() map[string]bool {
+
+	enabled := map[string]bool{
+		"zscore":   q.ZScore,
+		"outliers": q.Outliers != 0,
+	}
+
+	for name := range q.Windows() {
+		enabled[name] = true
+	}
+
+	return enabled
 }
 
 type TSDBqueryPayload struct {
@@ -37,6 +84,8 @@ type TSDBqueryPayload struct {
 	Queries      []TSDBquery `json:"queries"`
 	ShowTSUIDs   bool        `json:"showTSUIDs"`
 	MsResolution bool        `json:"msResolution"`
+	ShowSummary  bool        `json:"showSummary"`
+	ShowStats    bool        `json:"showStats"`
 }
 
 func (query TSDBqueryPayload) Validate() gobol.Error {
@@ -114,6 +163,24 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 			}
 		}
 
+		for name, w := range q.Windows() {
+			if err := query.checkWindow(name, *w); err != nil {
+				return err
+			}
+		}
+
+		if q.Outliers < 0 {
+			return errValidation(errors.New("outliers threshold needs to be a positive number"))
+		}
+
+		if q.HoltWinters != nil {
+			if err := query.checkHoltWinters(*q.HoltWinters); err != nil {
+				return err
+			}
+		}
+
+		enabled := q.pointFunctions()
+
 		if len(q.Order) == 0 {
 
 			if q.FilterValue != "" {
@@ -130,6 +197,12 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 				query.Queries[i].Order = append(query.Queries[i].Order, "rate")
 			}
 
+			for _, name := range PointOrder {
+				if enabled[name] {
+					query.Queries[i].Order = append(query.Queries[i].Order, name)
+				}
+			}
+
 		} else {
 
 			orderCheck := make([]string, len(q.Order))
@@ -236,6 +309,32 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 				orderCheck = append(orderCheck[:k], orderCheck[k+1:]...)
 			}
 
+			for _, name := range PointOrder {
+
+				k = 0
+				occur = 0
+				for j, order := range orderCheck {
+
+					if order == name {
+						k = j
+						occur++
+					}
+
+				}
+
+				if enabled[name] && occur == 0 {
+					return errValidation(fmt.Errorf("%s configured but no %s found in order array", name, name))
+				}
+
+				if occur > 1 {
+					return errValidation(fmt.Errorf("more than one %s found in order array", name))
+				}
+
+				if occur == 1 {
+					orderCheck = append(orderCheck[:k], orderCheck[k+1:]...)
+				}
+			}
+
 			if len(orderCheck) != 0 {
 				return errValidation(fmt.Errorf("invalid operations in order array %v", orderCheck))
 			}
@@ -246,6 +345,18 @@ func (query TSDBqueryPayload) Validate() gobol.Error {
 			return err
 		}
 
+		if q.Rank != nil {
+			if err := query.checkRank(*q.Rank); err != nil {
+				return err
+			}
+		}
+
+		if q.TimeShift != "" {
+			if err := query.checkDuration(q.TimeShift); err != nil {
+				return err
+			}
+		}
+
 	}
 
 	return nil
@@ -257,6 +368,105 @@ func (query TSDBqueryPayload) checkRate(opts TSDBrateOptions) gobol.Error {
 		return errRate("counter max needs to be a positive integer")
 	}
 
+	if opts.ResetValue < 0 {
+		return errRate("reset value needs to be a positive integer")
+	}
+
+	switch opts.Function {
+	case "", "rate", "irate", "increase", "delta", "nonNegativeDelta":
+	default:
+		return errRate(fmt.Sprintf(
+			"invalid rate function %s, use rate, irate, increase, delta or nonNegativeDelta",
+			opts.Function,
+		))
+	}
+
+	if opts.Unit != "" {
+		switch opts.Function {
+		case "", "rate", "irate":
+		default:
+			return errRate(fmt.Sprintf("%s doesn't take a unit", opts.Function))
+		}
+		if err := query.checkDuration(opts.Unit); err != nil {
+			return errRate(fmt.Sprintf("invalid rate unit %s", opts.Unit))
+		}
+	}
+
+	if opts.Window != "" {
+		if opts.Function != "increase" {
+			return errRate("only increase takes a window")
+		}
+		if err := query.checkDuration(opts.Window); err != nil {
+			return errRate(fmt.Sprintf("invalid increase window %s", opts.Window))
+		}
+	}
+
+	return nil
+}
+
+func (query TSDBqueryPayload) checkRank(rank TSDBrank) gobol.Error {
+
+	if rank.Type != "topk" && rank.Type != "bottomk" {
+		return errRank(fmt.Sprintf("invalid rank type %s", rank.Type))
+	}
+
+	if rank.Size < 1 {
+		return errRank("rank size needs to be bigger than 0")
+	}
+
+	switch rank.Function {
+	case "avg", "max", "min", "last":
+	default:
+		return errRank(fmt.Sprintf("invalid rank function %s", rank.Function))
+	}
+
+	return nil
+}
+
+func (query TSDBqueryPayload) checkWindow(name string, w TSDBwindow) gobol.Error {
+
+	switch name {
+	case "ewma":
+		if w.Alpha <= 0 || w.Alpha > 1 {
+			return errWindow(fmt.Sprintf("%s alpha needs to be bigger than 0 and smaller or equal to 1", name))
+		}
+		return nil
+	case "rollingPercentile":
+		if w.Percentile <= 0 || w.Percentile > 100 {
+			return errWindow(fmt.Sprintf("%s percentile needs to be bigger than 0 and smaller or equal to 100", name))
+		}
+	}
+
+	if n, err := strconv.Atoi(w.Window); err == nil {
+		if n < 1 {
+			return errWindow(fmt.Sprintf("%s window needs to be bigger than 0", name))
+		}
+		return nil
+	}
+
+	return query.checkDuration(w.Window)
+}
+
+func (query TSDBqueryPayload) checkHoltWinters(hw TSDBholtWinters) gobol.Error {
+
+	if n, err := strconv.Atoi(hw.Season); err == nil {
+		if n < 2 {
+			return errHoltWinters("holtWinters season needs to be bigger than 1")
+		}
+	} else if err := query.checkDuration(hw.Season); err != nil {
+		return err
+	}
+
+	for _, f := range []float64{hw.Alpha, hw.Beta, hw.Gamma} {
+		if f < 0 || f > 1 {
+			return errHoltWinters("holtWinters alpha, beta and gamma need to be between 0 and 1")
+		}
+	}
+
+	if hw.Deviations < 0 {
+		return errHoltWinters("holtWinters deviations needs to be a positive number")
+	}
+
 	return nil
 }
 
@@ -346,6 +556,16 @@ func (query TSDBqueryPayload) checkFilter(filters []TSDBfilter) gobol.Error {
 			return err
 		}
 
+		if ft == "not_key" {
+			if filter.Filter != "" {
+				return errFilter("not_key filter value must be empty")
+			}
+			if filter.GroupBy {
+				return errFilter("not_key filter cannot be used with groupBy")
+			}
+			continue
+		}
+
 		if err := query.checkFilterField("filter", ft, filter.Filter); err != nil {
 			return err
 		}
@@ -368,17 +588,16 @@ func (query TSDBqueryPayload) checkDuration(s string) gobol.Error {
 		if err != nil {
 			return errCheckDuration(err)
 		}
-		return nil
-	}
-
-	switch s[len(s)-1:] {
-	case "s", "m", "h", "d", "w", "n", "y":
-		n, err = strconv.Atoi(string(s[:len(s)-1]))
-		if err != nil {
-			return errCheckDuration(err)
+	} else {
+		switch s[len(s)-1:] {
+		case "s", "m", "h", "d", "w", "n", "y":
+			n, err = strconv.Atoi(string(s[:len(s)-1]))
+			if err != nil {
+				return errCheckDuration(err)
+			}
+		default:
+			return errCheckDuration(errors.New("Invalid unit"))
 		}
-	default:
-		return errCheckDuration(errors.New("Invalid unit"))
 	}
 
 	if n < 1 {
@@ -416,10 +635,47 @@ func (query TSDBqueryPayload) checkFilterField(n, tf, f string) gobol.Error {
 	return nil
 }
 
+// TSDBrateOptions follows the OpenTSDB rate options. A counter that
+// decreases wrapped at CounterMax, or was reset when DropResets is set and
+// the point is dropped. Without CounterMax counters wrap at 2^64, as in
+// OpenTSDB, 32 bit counters need CounterMax 4294967295.
+// Function is rate, irate, increase, delta or nonNegativeDelta. Unit is the
+// duration rates are given per, one second by default, and Window the
+// duration increase sums, the whole serie by default
 type TSDBrateOptions struct {
 	Counter    bool   `json:"counter"`
 	CounterMax *int64 `json:"counterMax,omitempty"`
 	ResetValue int64  `json:"resetValue,omitempty"`
+	DropResets bool   `json:"dropResets,omitempty"`
+	Function   string `json:"function,omitempty"`
+	Unit       string `json:"unit,omitempty"`
+	Window     string `json:"window,omitempty"`
+}
+
+// TSDBrank keeps only the Size series with the highest (topk) or lowest
+// (bottomk) value of Function after grouping
+type TSDBrank struct {
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	Function string `json:"function"`
+}
+
+// TSDBwindow configures a moving window function. Window is either a number
+// of points or a duration like 5m
+type TSDBwindow struct {
+	Window     string  `json:"window,omitempty"`
+	Alpha      float64 `json:"alpha,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"`
+}
+
+// TSDBholtWinters configures a Holt-Winters forecast. Season is either a
+// number of points or a duration, zero values use the defaults
+type TSDBholtWinters struct {
+	Season     string  `json:"season"`
+	Alpha      float64 `json:"alpha,omitempty"`
+	Beta       float64 `json:"beta,omitempty"`
+	Gamma      float64 `json:"gamma,omitempty"`
+	Deviations float64 `json:"deviations,omitempty"`
 }
 
 type TSDBfilter struct {
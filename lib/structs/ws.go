@@ -26,11 +26,27 @@ type DataOperations struct {
 	Rate        RateOperation
 	Order       []string
 	FilterValue FilterValueOperation
+	Windows     map[string]WindowOperation
+	TimeShift   int64
+	ZScore      bool
+	Outliers    float64
+}
+
+type WindowOperation struct {
+	Points     int
+	Unit       string
+	Value      int
+	Alpha      float64
+	Percentile float64
 }
 
 type RateOperation struct {
 	Enabled bool
 	Options TSDBrateOptions
+	// Unit is the duration, in milliseconds, rates are given per and Window
+	// the duration increase sums
+	Unit   int64
+	Window int64
 }
 
 type FilterValueOperation struct {
@@ -0,0 +1,80 @@
+package tserr
+
+import (
+	"net/http"
+
+	"github.com/uol/gobol"
+)
+
+// Code identifies an error to the clients. Codes are part of the API and
+// must not change, messages may
+type Code string
+
+const (
+	InvalidRequest     Code = "INVALID_REQUEST"
+	InvalidJSON        Code = "INVALID_JSON"
+	InvalidMetric      Code = "INVALID_METRIC"
+	InvalidTag         Code = "INVALID_TAG"
+	MissingTag         Code = "MISSING_TAG"
+	TagLimit           Code = "TAG_LIMIT"
+	InvalidValue       Code = "INVALID_VALUE"
+	InvalidTimestamp   Code = "INVALID_TIMESTAMP"
+	MissingKeyspace    Code = "MISSING_KEYSPACE"
+	UnknownKeyspace    Code = "UNKNOWN_KEYSPACE"
+	ReservedKeyspace   Code = "RESERVED_KEYSPACE"
+	InvalidQuery       Code = "INVALID_QUERY"
+	QueryTooLarge      Code = "QUERY_TOO_LARGE"
+	QueryCanceled      Code = "QUERY_CANCELED"
+	QueryTimeout       Code = "QUERY_TIMEOUT"
+	NotFound           Code = "NOT_FOUND"
+	Conflict           Code = "CONFLICT"
+	Unauthorized       Code = "UNAUTHORIZED"
+	Forbidden          Code = "FORBIDDEN"
+	QuotaExceeded      Code = "QUOTA_EXCEEDED"
+	StorageUnavailable Code = "STORAGE_UNAVAILABLE"
+	ShuttingDown       Code = "SHUTTING_DOWN"
+	Internal           Code = "INTERNAL"
+)
+
+type coded interface {
+	Code() Code
+}
+
+// CodeOf returns the code of an error. Errors created without a code, or
+// not created by this package, get the code of their status
+func CodeOf(gerr gobol.Error) Code {
+
+	if c, ok := gerr.(coded); ok {
+		return c.Code()
+	}
+
+	return codeOfStatus(gerr.StatusCode())
+}
+
+func codeOfStatus(status int) Code {
+
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidRequest
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusRequestTimeout:
+		return QueryCanceled
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusTooManyRequests:
+		return QuotaExceeded
+	case http.StatusGatewayTimeout:
+		return QueryTimeout
+	}
+
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+		return InvalidRequest
+	}
+
+	return Internal
+}
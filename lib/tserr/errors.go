@@ -9,6 +9,18 @@ func New(e error, msg string, httpCode int, lf map[string]interface{}) gobol.Err
 		e,
 		msg,
 		httpCode,
+		"",
+		lf,
+	}
+}
+
+// NewCode creates an error with a code that tells the clients what failed
+func NewCode(e error, msg string, code Code, httpCode int, lf map[string]interface{}) gobol.Error {
+	return customError{
+		e,
+		msg,
+		httpCode,
+		code,
 		lf,
 	}
 }
@@ -17,6 +29,7 @@ type customError struct {
 	error
 	msg      string
 	httpCode int
+	code     Code
 	lf       map[string]interface{}
 }
 
@@ -28,6 +41,13 @@ func (e customError) StatusCode() int {
 	return e.httpCode
 }
 
+func (e customError) Code() Code {
+	if e.code == "" {
+		return codeOfStatus(e.httpCode)
+	}
+	return e.code
+}
+
 func (e customError) LogFields() map[string]interface{} {
 	return e.lf
 }
@@ -0,0 +1,48 @@
+package tserr
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/uol/gobol"
+)
+
+var logger *logrus.Logger
+
+func SetLogger(l *logrus.Logger) {
+	logger = l
+}
+
+type errorJSON struct {
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+	Code    Code   `json:"code"`
+}
+
+// Fail writes the error response of a REST handler, with the error code
+func Fail(w http.ResponseWriter, gerr gobol.Error) {
+
+	if logger != nil {
+		logger.WithFields(gerr.LogFields()).Error(gerr.Error())
+	}
+
+	status := gerr.StatusCode()
+
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(errorJSON{
+		Error:   gerr.Error(),
+		Message: gerr.Message(),
+		Code:    CodeOf(gerr),
+	})
+	if err != nil && logger != nil {
+		logger.Error(err)
+	}
+}
@@ -44,3 +44,15 @@ func (sts *StatsTS) ValueAdd(callerID string, metric string, tags map[string]str
 		}).Error(err)
 	}
 }
+
+// ValueSet keeps v as the value of a gauge until it is set again
+func (sts *StatsTS) ValueSet(callerID string, metric string, tags map[string]string, v float64) {
+	err := sts.stats.SetValue(metric, tags, sts.interval, true, false, v)
+	if err != nil {
+		sts.log.WithFields(logrus.Fields{
+			"package": callerID,
+			"func":    "statsValueSet",
+			"metric":  metric,
+		}).Error(err)
+	}
+}
@@ -0,0 +1,20 @@
+package udp
+
+import "syscall"
+
+// soReusePort is SO_REUSEPORT, the syscall package doesn't define it on linux
+const soReusePort = 0xf
+
+func reusePort(network, address string, c syscall.RawConn) error {
+
+	var serr error
+
+	err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package udp
+
+import (
+	"errors"
+	"syscall"
+)
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is only supported on linux, use a single socket")
+}
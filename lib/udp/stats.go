@@ -0,0 +1,14 @@
+package udp
+
+// statsDropped runs on the reader, a goroutine per drop is what the queue
+// is there to avoid
+func statsDropped(port, reason string) {
+	statsIncrement(
+		"mycenae.udp.dropped",
+		map[string]string{"port": port, "reason": reason},
+	)
+}
+
+func statsIncrement(metric string, tags map[string]string) {
+	stats.Increment("udp", metric, tags)
+}
@@ -1,76 +1,138 @@
 package udp
 
 import (
+	"context"
+	"errors"
 	"net"
+	"runtime"
+	"sync"
 
 	"github.com/Sirupsen/logrus"
 
 	"github.com/uol/mycenae/lib/structs"
+	"github.com/uol/mycenae/lib/tsstats"
 )
 
 var (
 	gblog *logrus.Logger
+	stats *tsstats.StatsTS
+)
+
+// maxDatagram fits the largest payload a UDP datagram can carry
+const maxDatagram = 65535
+
+const (
+	dropNew    = "new"
+	dropOldest = "oldest"
 )
 
 type udpHandler interface {
 	HandleUDPpacket(buf []byte, addr string)
-	Stop()
 }
 
-func New(gbl *logrus.Logger, setUDP structs.SettingsUDP, handler udpHandler) *UDPserver {
+type datagram struct {
+	buf  []byte
+	addr string
+}
+
+// New creates a UDP server that reads datagrams from one or more sockets into
+// a bounded queue consumed by a pool of workers. When the queue is full the
+// datagram is dropped, or the oldest queued datagram with the oldest policy
+func New(gbl *logrus.Logger, sts *tsstats.StatsTS, setUDP structs.SettingsUDP, handler udpHandler) (*UDPserver, error) {
 
 	gblog = gbl
+	stats = sts
+
+	if setUDP.Sockets <= 0 {
+		setUDP.Sockets = 1
+	}
+	if setUDP.Workers <= 0 {
+		setUDP.Workers = runtime.NumCPU()
+	}
+	if setUDP.QueueSize <= 0 {
+		setUDP.QueueSize = setUDP.Workers
+	}
+
+	switch setUDP.DropPolicy {
+	case "":
+		setUDP.DropPolicy = dropNew
+	case dropNew, dropOldest:
+	default:
+		return nil, errors.New("UDP dropPolicy needs to be new or oldest")
+	}
 
 	return &UDPserver{
 		handler:  handler,
 		settings: setUDP,
-	}
+		queue:    make(chan datagram, setUDP.QueueSize),
+	}, nil
 }
 
 type UDPserver struct {
 	handler  udpHandler
 	settings structs.SettingsUDP
+	queue    chan datagram
+	sockets  []*net.UDPConn
+	readers  sync.WaitGroup
+	workers  sync.WaitGroup
+	mtx      sync.Mutex
 	shutdown bool
-	closed   chan struct{}
 }
 
-func (us UDPserver) Start() {
-	go us.asyncStart()
-}
+func (us *UDPserver) Start() {
 
-func (us UDPserver) asyncStart() {
+	for i := 0; i < us.settings.Sockets; i++ {
+		sock, err := us.listen()
+		if err != nil {
+			gblog.Fatal("listen: ", err)
+		}
+		us.sockets = append(us.sockets, sock)
+	}
 
-	port := ":" + us.settings.Port
+	gblog.Info("listen: ", "binded to port: ", us.settings.Port, " sockets: ", len(us.sockets))
 
-	addr, err := net.ResolveUDPAddr("udp", port)
+	for i := 0; i < us.settings.Workers; i++ {
+		us.workers.Add(1)
+		go us.work()
+	}
 
-	if err != nil {
-		gblog.Fatalf("addr: ", err)
-	} else {
-		gblog.Info("addr: ", "resolved")
+	for _, sock := range us.sockets {
+		us.readers.Add(1)
+		go us.read(sock)
 	}
+}
+
+func (us *UDPserver) listen() (*net.UDPConn, error) {
 
-	sock, err := net.ListenUDP("udp", addr)
+	lc := net.ListenConfig{}
 
+	if us.settings.Sockets > 1 {
+		lc.Control = reusePort
+	}
+
+	conn, err := lc.ListenPacket(context.Background(), "udp", ":"+us.settings.Port)
 	if err != nil {
-		gblog.Fatal("listen: ", err)
-	} else {
-		gblog.Info("listen: ", "binded to port: ", us.settings.Port)
+		return nil, err
 	}
 
-	defer sock.Close()
+	sock := conn.(*net.UDPConn)
 
 	err = sock.SetReadBuffer(us.settings.ReadBuffer)
-
 	if err != nil {
-		gblog.Fatal("set buffer: ", err)
-	} else {
-		gblog.Info("set buffer: ", "setted")
+		sock.Close()
+		return nil, err
 	}
 
-	for {
-		buf := make([]byte, 1024)
+	return sock, nil
+}
+
+func (us *UDPserver) read(sock *net.UDPConn) {
+
+	defer us.readers.Done()
 
+	buf := make([]byte, maxDatagram)
+
+	for {
 		rlen, addr, err := sock.ReadFromUDP(buf)
 
 		saddr := ""
@@ -79,23 +141,77 @@ func (us UDPserver) asyncStart() {
 			saddr = addr.IP.String()
 		}
 		if err != nil {
+			if us.stopping() {
+				return
+			}
 			gblog.Errorf("read buffer from %s : %s", saddr, err)
-		} else {
-			go us.handler.HandleUDPpacket(buf[0:rlen], saddr)
+			continue
+		}
+
+		packet := make([]byte, rlen)
+		copy(packet, buf[:rlen])
+
+		us.enqueue(datagram{buf: packet, addr: saddr})
+	}
+}
+
+func (us *UDPserver) enqueue(d datagram) {
+
+	for {
+		select {
+		case us.queue <- d:
+			return
+		default:
 		}
 
-		if us.shutdown {
-			us.closed <- struct{}{}
+		if us.settings.DropPolicy == dropNew {
+			statsDropped(us.settings.Port, "queueFull")
 			return
 		}
+
+		select {
+		case <-us.queue:
+			statsDropped(us.settings.Port, "evicted")
+		default:
+		}
 	}
 }
 
+func (us *UDPserver) work() {
+
+	defer us.workers.Done()
+
+	for d := range us.queue {
+		us.handler.HandleUDPpacket(d.buf, d.addr)
+	}
+}
+
+func (us *UDPserver) stopping() bool {
+	us.mtx.Lock()
+	defer us.mtx.Unlock()
+	return us.shutdown
+}
+
+// Stop closes the sockets and returns after the datagrams already queued
+// are handled
 func (us *UDPserver) Stop() {
+
+	us.mtx.Lock()
 	us.shutdown = true
-	select {
-	case <-us.closed:
-		us.handler.Stop()
-		return
+	us.mtx.Unlock()
+
+	for _, sock := range us.sockets {
+		sock.Close()
 	}
+
+	us.readers.Wait()
+
+	close(us.queue)
+
+	us.workers.Wait()
+}
+
+// Listening tells if the server is reading datagrams
+func (us *UDPserver) Listening() bool {
+	return len(us.sockets) > 0 && !us.stopping()
 }
@@ -29,7 +29,16 @@ func errNotFound(f string) gobol.Error {
 }
 
 func errPersist(f string, e error) gobol.Error {
-	return errBasic(f, e.Error(), http.StatusInternalServerError, e)
+	return tserr.NewCode(
+		e,
+		e.Error(),
+		tserr.StorageUnavailable,
+		http.StatusInternalServerError,
+		map[string]interface{}{
+			"package": "udpError",
+			"func":    f,
+		},
+	)
 }
 
 func errParamSize(f string, e error) gobol.Error {
@@ -18,7 +18,7 @@ type persistence struct {
 func (persist *persistence) GetErrorInfo(key string) ([]ErrorInfo, gobol.Error) {
 	start := time.Now()
 
-	var id, errorMsg, payload string
+	var id, errorMsg, code, payload string
 	date := time.Time{}
 	errorsInfo := []ErrorInfo{}
 	var err error
@@ -26,14 +26,15 @@ func (persist *persistence) GetErrorInfo(key string) ([]ErrorInfo, gobol.Error)
 	for _, cons := range persist.consistencies {
 
 		iter := persist.cassandra.Query(
-			`SELECT tsid, error, message, date FROM ts_error WHERE tsid = ? ALLOW FILTERING`,
+			`SELECT tsid, error, error_code, message, date FROM ts_error WHERE tsid = ? ALLOW FILTERING`,
 			key,
 		).Consistency(cons).RoutingKey([]byte(key)).Iter()
 
-		for iter.Scan(&id, &errorMsg, &payload, &date) {
+		for iter.Scan(&id, &errorMsg, &code, &payload, &date) {
 			ei := ErrorInfo{
 				ID:      id,
 				Error:   errorMsg,
+				Code:    code,
 				Message: payload,
 				Date:    date,
 			}
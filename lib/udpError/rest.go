@@ -7,6 +7,8 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/uol/gobol/rip"
+
+	"github.com/uol/mycenae/lib/tserr"
 )
 
 func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -14,7 +16,7 @@ func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps
 	ks := ps.ByName("keyspace")
 	if ks == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/errortags", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("ListErrorTags"))
+		tserr.Fail(w, errNotFound("ListErrorTags"))
 		return
 	}
 
@@ -24,7 +26,7 @@ func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps
 
 	gerr := rip.FromJSON(r, &query)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -39,12 +41,12 @@ func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps
 		size, err := strconv.Atoi(sizeStr)
 		if err != nil {
 			gerr := errParamSize("ListErrorTags", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if size <= 0 {
 			gerr := errParamSize("ListErrorTags", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -58,12 +60,12 @@ func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps
 		from, err := strconv.Atoi(fromStr)
 		if err != nil {
 			gerr := errParamFrom("ListErrorTags", err)
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 		if from < 0 {
 			gerr := errParamFrom("ListErrorTags", errors.New(""))
-			rip.Fail(w, gerr)
+			tserr.Fail(w, gerr)
 			return
 		}
 	}
@@ -77,12 +79,12 @@ func (uerror *UDPerror) ListErrorTags(w http.ResponseWriter, r *http.Request, ps
 		int64(from),
 	)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(keys) == 0 {
 		gerr := errNoContent("ListErrorTags")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
@@ -100,7 +102,7 @@ func (uerror *UDPerror) GetErrorInfo(w http.ResponseWriter, r *http.Request, ps
 	ks := ps.ByName("keyspace")
 	if ks == "" {
 		rip.AddStatsMap(r, map[string]string{"path": "/keyspaces/#keyspace/errors/#error", "keyspace": "empty"})
-		rip.Fail(w, errNotFound("GetErrorInfo"))
+		tserr.Fail(w, errNotFound("GetErrorInfo"))
 		return
 	}
 
@@ -108,18 +110,18 @@ func (uerror *UDPerror) GetErrorInfo(w http.ResponseWriter, r *http.Request, ps
 
 	errID := ps.ByName("error")
 	if errID == "" {
-		rip.Fail(w, errNotFound("GetErrorInfo"))
+		tserr.Fail(w, errNotFound("GetErrorInfo"))
 		return
 	}
 
 	errorList, gerr := uerror.getErrorInfo(ks, errID)
 	if gerr != nil {
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 	if len(errorList) == 0 {
 		gerr := errNoContent("GetErrorInfo")
-		rip.Fail(w, gerr)
+		tserr.Fail(w, gerr)
 		return
 	}
 
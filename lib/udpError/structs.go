@@ -9,6 +9,7 @@ import (
 type ErrorInfo struct {
 	ID      string    `json:"id"`
 	Error   string    `json:"error"`
+	Code    string    `json:"code"`
 	Message string    `json:"message"`
 	Date    time.Time `json:"date"`
 }
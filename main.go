@@ -19,9 +19,11 @@ import (
 	"github.com/uol/gobol/saw"
 	"github.com/uol/gobol/snitch"
 
+	"github.com/uol/mycenae/lib/auth"
 	"github.com/uol/mycenae/lib/bcache"
 	"github.com/uol/mycenae/lib/collector"
 	"github.com/uol/mycenae/lib/keyspace"
+	"github.com/uol/mycenae/lib/limiter"
 	"github.com/uol/mycenae/lib/plot"
 	"github.com/uol/mycenae/lib/rest"
 	"github.com/uol/mycenae/lib/structs"
@@ -112,19 +114,39 @@ func main() {
 		os.Exit(1)
 	}
 
-	coll, err := collector.New(tsLogger, tssts, cass, es, bc, settings, wcs)
+	lim, gerr := limiter.New(tsLogger.General, tssts, ks, settings.QuotaRefreshInterval)
+	if gerr != nil {
+		tsLogger.General.Error(gerr)
+		os.Exit(1)
+	}
+
+	au, gerr := auth.New(tssts, settings.Auth)
+	if gerr != nil {
+		tsLogger.General.Error(gerr)
+		os.Exit(1)
+	}
+
+	coll, err := collector.New(tsLogger, tssts, cass, es, bc, lim, au, settings, wcs)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	uV2server := udp.New(tsLogger.General, settings.UDPserverV2, coll)
+	uV2server, err := udp.New(tsLogger.General, tssts, settings.UDPserverV2, coll)
+	if err != nil {
+		tsLogger.General.Error(err)
+		os.Exit(1)
+	}
 
 	uV2server.Start()
 
 	collectorV1 := collector.UDPv1{}
 
-	uV1server := udp.New(tsLogger.General, settings.UDPserver, collectorV1)
+	uV1server, err := udp.New(tsLogger.General, tssts, settings.UDPserver, collectorV1)
+	if err != nil {
+		tsLogger.General.Error(err)
+		os.Exit(1)
+	}
 
 	uV1server.Start()
 
@@ -134,11 +156,16 @@ func main() {
 		cass,
 		es,
 		bc,
+		lim,
 		settings.ElasticSearch.Index,
 		settings.MaxTimeseries,
 		settings.MaxConcurrentTimeseries,
 		settings.MaxConcurrentReads,
 		settings.LogQueryTSthreshold,
+		settings.SlowQueryThreshold,
+		settings.MaxQueryDuration,
+		settings.MetaLastSeenInterval,
+		settings.QueryCache,
 		rcs,
 	)
 
@@ -147,6 +174,8 @@ func main() {
 		os.Exit(1)
 	}
 
+	coll.OnWrite(p.InvalidateCache)
+
 	uError := udpError.New(
 		tsLogger.General,
 		tssts,
@@ -157,7 +186,7 @@ func main() {
 		rcs,
 	)
 
-	tsRest := rest.New(
+	tsRest, err := rest.New(
 		tsLogger,
 		sts,
 		p,
@@ -165,9 +194,15 @@ func main() {
 		ks,
 		bc,
 		coll,
+		au,
+		map[string]*udp.UDPserver{"udpV2": uV2server, "udpV1": uV1server},
 		settings.HTTPserver,
-		settings.Probe.Threshold,
+		settings.Probe,
 	)
+	if err != nil {
+		tsLogger.General.Error(err)
+		os.Exit(1)
+	}
 
 	tsRest.Start()
 
@@ -181,7 +216,7 @@ func main() {
 		sig := <-signalChannel
 		switch sig {
 		case os.Interrupt, syscall.SIGTERM:
-			stop(tsLogger, tsRest, coll)
+			stop(tsLogger, tsRest, uV2server, uV1server, coll, bc)
 			return
 		case syscall.SIGHUP:
 			//THIS IS A HACK DO NOT EXTEND IT. THE FEATURE IS NICE BUT NEEDS TO BE DONE CORRECTLY!!!!!
@@ -251,7 +286,13 @@ func parseConsistencies(names []string) ([]gocql.Consistency, error) {
 	return tmp, nil
 }
 
-func stop(logger *structs.TsLog, rest *rest.REST, collector *collector.Collector) {
+func stop(
+	logger *structs.TsLog,
+	rest *rest.REST,
+	uV2server, uV1server *udp.UDPserver,
+	collector *collector.Collector,
+	bc *bcache.Bcache,
+) {
 
 	fmt.Println("Stopping REST")
 	logger.General.Info("Stopping REST")
@@ -260,7 +301,21 @@ func stop(logger *structs.TsLog, rest *rest.REST, collector *collector.Collector
 
 	fmt.Println("Stopping UDPv2")
 	logger.General.Info("Stopping UDPv2")
-	collector.Stop()
+	uV2server.Stop()
 	fmt.Println("UDPv2 stopped")
 
+	fmt.Println("Stopping UDPv1")
+	logger.General.Info("Stopping UDPv1")
+	uV1server.Stop()
+	fmt.Println("UDPv1 stopped")
+
+	fmt.Println("Stopping collector")
+	logger.General.Info("Stopping collector")
+	collector.Stop()
+	fmt.Println("Collector stopped")
+
+	if gerr := bc.Close(); gerr != nil {
+		logger.General.Error(gerr)
+	}
+
 }